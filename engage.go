@@ -0,0 +1,110 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	engageUrl = "/api/2.0/engage"
+)
+
+var (
+	ErrEngageCredentialsRequired = errors.New("engage query requires a service account or api secret")
+)
+
+// PeopleProfile is a user profile returned by the Engage Query API
+type PeopleProfile struct {
+	DistinctID string         `json:"$distinct_id"`
+	Properties map[string]any `json:"$properties"`
+}
+
+type engageResponse struct {
+	Page      int             `json:"page"`
+	PageSize  int             `json:"page_size"`
+	SessionID string          `json:"session_id"`
+	Results   []PeopleProfile `json:"results"`
+	Status    string          `json:"status"`
+	Total     int             `json:"total"`
+}
+
+// PeopleGetOne calls the Engage Query API for a single profile
+// https://developer.mixpanel.com/reference/engage-query
+// Need to provide a service account or api secret to the client
+// found is false if no profile exists for the distinct id
+func (a *ApiClient) PeopleGetOne(ctx context.Context, distinctID string) (map[string]any, bool, error) {
+	form := url.Values{}
+	form.Add("distinct_id", distinctID)
+
+	response, err := a.doEngageRequest(ctx, form)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(response.Results) == 0 {
+		return nil, false, nil
+	}
+
+	return response.Results[0].Properties, true, nil
+}
+
+// PeopleQuery calls the Engage Query API for every profile matching where
+// https://developer.mixpanel.com/reference/engage-query
+// Need to provide a service account or api secret to the client
+// All result pages are fetched, a nil or empty where returns every profile
+func (a *ApiClient) PeopleQuery(ctx context.Context, where *WhereBuilder) ([]PeopleProfile, error) {
+	form := url.Values{}
+	if w := where.String(); w != "" {
+		form.Add("where", w)
+	}
+
+	var profiles []PeopleProfile
+	for {
+		response, err := a.doEngageRequest(ctx, form)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, response.Results...)
+
+		if response.SessionID == "" || len(response.Results) == 0 || len(response.Results) < response.PageSize {
+			return profiles, nil
+		}
+		form.Set("session_id", response.SessionID)
+		form.Set("page", strconv.Itoa(response.Page+1))
+	}
+}
+
+func (a *ApiClient) doEngageRequest(ctx context.Context, form url.Values) (*engageResponse, error) {
+	if a.serviceAccount == nil && a.apiSecret == "" {
+		return nil, ErrEngageCredentialsRequired
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodPost,
+		a.queryEndpoint+engageUrl,
+		strings.NewReader(form.Encode()),
+		a.exportServiceAccount(), acceptJson(), applicationFormData(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query engage: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+		var r engageResponse
+		if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to json decode response body: %w", err)
+		}
+		return &r, nil
+	default:
+		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+}
@@ -0,0 +1,158 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEngageEndpoint(t *testing.T, client *ApiClient, testForm func(req *http.Request), httpResponse *http.Response) {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.queryEndpoint, engageUrl), func(req *http.Request) (*http.Response, error) {
+		require.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("content-type"))
+		require.Equal(t, "application/json", req.Header.Get("accept"))
+
+		require.NoError(t, req.ParseForm())
+		testForm(req)
+
+		return httpResponse, nil
+	})
+}
+
+func TestPeopleGetOne(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("profile found", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupEngageEndpoint(t, mp, func(req *http.Request) {
+			require.Equal(t, "some-id", req.PostForm.Get("distinct_id"))
+			require.Equal(t, "117", req.URL.Query().Get("project_id"))
+			require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("username:secret")), req.Header.Get("authorization"))
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"page": 0,
+				"page_size": 1000,
+				"results": [
+					{
+						"$distinct_id": "some-id",
+						"$properties": {
+							"$email": "some-email",
+							"plan": "premium"
+						}
+					}
+				],
+				"status": "ok",
+				"total": 1
+			}
+			`)),
+		})
+
+		properties, found, err := mp.PeopleGetOne(ctx, "some-id")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "some-email", properties["$email"])
+		require.Equal(t, "premium", properties["plan"])
+	})
+
+	t.Run("profile not found", func(t *testing.T) {
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageEndpoint(t, mp, func(req *http.Request) {
+			require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("api-secret:")), req.Header.Get("authorization"))
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"page": 0, "page_size": 1000, "results": [], "status": "ok", "total": 0}`)),
+		})
+
+		properties, found, err := mp.PeopleGetOne(ctx, "some-id")
+		require.NoError(t, err)
+		require.False(t, found)
+		require.Nil(t, properties)
+	})
+
+	t.Run("requires credentials", func(t *testing.T) {
+		mp := NewApiClient("token")
+		_, _, err := mp.PeopleGetOne(ctx, "some-id")
+		require.ErrorIs(t, err, ErrEngageCredentialsRequired)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageEndpoint(t, mp, func(req *http.Request) {}, &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Body:       io.NopCloser(strings.NewReader(`{"error": "unauthorized", "status": "error"}`)),
+		})
+
+		_, _, err := mp.PeopleGetOne(ctx, "some-id")
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, http.StatusUnauthorized, httpErr.Status)
+	})
+}
+
+func TestPeopleQuery(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends the where expression", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupEngageEndpoint(t, mp, func(req *http.Request) {
+			require.Equal(t, `properties["$email"] == "some-email"`, req.PostForm.Get("where"))
+			require.Empty(t, req.PostForm.Get("session_id"))
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"page": 0,
+				"page_size": 1000,
+				"session_id": "some-session",
+				"results": [
+					{"$distinct_id": "some-id", "$properties": {"$email": "some-email"}}
+				],
+				"status": "ok",
+				"total": 1
+			}
+			`)),
+		})
+
+		profiles, err := mp.PeopleQuery(ctx, NewWhere().Equals("$email", "some-email"))
+		require.NoError(t, err)
+		require.Equal(t, []PeopleProfile{
+			{DistinctID: "some-id", Properties: map[string]any{"$email": "some-email"}},
+		}, profiles)
+	})
+
+	t.Run("fetches every page", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, engageUrl), func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, req.ParseForm())
+			switch req.PostForm.Get("page") {
+			case "":
+				return httpmock.NewStringResponse(http.StatusOK, `{"page": 0, "page_size": 1, "session_id": "some-session", "results": [{"$distinct_id": "id-1"}], "total": 2}`), nil
+			case "1":
+				require.Equal(t, "some-session", req.PostForm.Get("session_id"))
+				return httpmock.NewStringResponse(http.StatusOK, `{"page": 1, "page_size": 1, "session_id": "some-session", "results": [{"$distinct_id": "id-2"}], "total": 2}`), nil
+			default:
+				return httpmock.NewStringResponse(http.StatusOK, `{"page": 2, "page_size": 1, "session_id": "some-session", "results": [], "total": 2}`), nil
+			}
+		})
+
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		profiles, err := mp.PeopleQuery(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, profiles, 2)
+		require.Equal(t, "id-1", profiles[0].DistinctID)
+		require.Equal(t, "id-2", profiles[1].DistinctID)
+	})
+}
@@ -1,12 +1,20 @@
 package mixpanel
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -18,9 +26,272 @@ const (
 	ExportNoWhereFilter string = ""
 )
 
+// ExportOption changes how a single export request is made
+type ExportOption func(config *exportConfig)
+
+type exportConfig struct {
+	projectID     int
+	omitProjectID bool
+	useNumber     bool
+}
+
+func (a *ApiClient) newExportConfig(options []ExportOption) exportConfig {
+	config := exportConfig{
+		projectID: a.projectID,
+	}
+	for _, o := range options {
+		o(&config)
+	}
+	return config
+}
+
+// ExportProjectID exports from projectID instead of the client's service account project
+// For service accounts that span multiple projects
+func ExportProjectID(projectID int) ExportOption {
+	return func(config *exportConfig) {
+		config.projectID = projectID
+		config.omitProjectID = false
+	}
+}
+
+// ExportWithoutProjectID does not send the project_id param even when using a service account
+func ExportWithoutProjectID() ExportOption {
+	return func(config *exportConfig) {
+		config.omitProjectID = true
+	}
+}
+
+// ExportUseNumber decodes numeric property values as json.Number instead of float64
+// Use it to keep large integer ids (over 2^53) exact and formatted as they were sent
+func ExportUseNumber() ExportOption {
+	return func(config *exportConfig) {
+		config.useNumber = true
+	}
+}
+
+// ExportMapper transforms an exported event
+// returning a nil event drops it and returning an error aborts the export
+type ExportMapper func(event *Event) (*Event, error)
+
 // Export calls the Raw Export API
 // https://developer.mixpanel.com/reference/raw-event-export
+// where can be built with NewWhere, e.g. NewWhere().Equals("plan", "pro").String()
+// The export is resumed if the connection drops, so the $insert_id of every event is kept in memory
+// until it returns, that memory grows with the size of the export
 func (a *ApiClient) Export(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string) ([]*Event, error) {
+	return a.ExportWithOptions(ctx, fromDate, toDate, limit, event, where)
+}
+
+// ExportWithOptions calls the Raw Export API like Export with options for the request
+// It keeps the $insert_id of every event in memory like Export
+func (a *ApiClient) ExportWithOptions(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, options ...ExportOption) ([]*Event, error) {
+	var results []*Event
+	err := a.exportEach(ctx, fromDate, toDate, limit, event, where, options, func(e *Event) error {
+		results = append(results, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ExportMapped calls the Raw Export API and applies mapper to each event as it is decoded
+// Useful to rename or drop events and properties when migrating between projects
+// Like Export the $insert_id of every event is kept in memory, even for dropped events
+func (a *ApiClient) ExportMapped(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, mapper ExportMapper, options ...ExportOption) ([]*Event, error) {
+	var results []*Event
+	err := a.exportEach(ctx, fromDate, toDate, limit, event, where, options, func(e *Event) error {
+		mapped, err := mapper(e)
+		if err != nil {
+			return fmt.Errorf("failed to map event: %w", err)
+		}
+		if mapped != nil {
+			results = append(results, mapped)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ExportByUser calls the Raw Export API and groups the events by their distinct_id
+// Each user's events are sorted by time, events without a distinct_id are under the "" key
+// The $insert_id of every event is kept in memory to resume the export, see Export
+func (a *ApiClient) ExportByUser(ctx context.Context, fromDate, toDate time.Time, event, where string, options ...ExportOption) (map[string][]*Event, error) {
+	results := make(map[string][]*Event)
+	err := a.exportEach(ctx, fromDate, toDate, ExportNoLimit, event, where, options, func(e *Event) error {
+		var distinctID string
+		switch id := e.Properties[propertyDistinctID].(type) {
+		case string:
+			distinctID = id
+		case json.Number:
+			distinctID = id.String()
+		case float64:
+			distinctID = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+		results[distinctID] = append(results[distinctID], e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, events := range results {
+		sort.SliceStable(events, func(i, j int) bool {
+			ti, _ := events[i].timeMillis()
+			tj, _ := events[j].timeMillis()
+			return ti < tj
+		})
+	}
+
+	return results, nil
+}
+
+// exportAuth uses the service account if available and adds the configured project_id
+// or falls back to apiSecret
+func (a *ApiClient) exportAuth(config exportConfig) httpOptions {
+	return func(req *http.Request) {
+		if a.serviceAccount == nil {
+			req.SetBasicAuth(a.apiSecret, "")
+			return
+		}
+
+		req.SetBasicAuth(a.serviceAccount.Username, a.serviceAccount.Secret)
+		if !config.omitProjectID {
+			values := url.Values{}
+			values.Add("project_id", strconv.Itoa(config.projectID))
+			addQueryParams(values)(req)
+		}
+	}
+}
+
+// exportMaxResumes is how many times an export is resumed after the connection drops mid-stream
+const exportMaxResumes = 3
+
+// exportDroppedError is returned when the export stream is cut off partway through
+type exportDroppedError struct {
+	err error
+}
+
+func (e *exportDroppedError) Error() string {
+	return fmt.Sprintf("failed to decode event:%v", e.err)
+}
+
+func (e *exportDroppedError) Unwrap() error {
+	return e.err
+}
+
+// isStreamDropped reports whether err is a truncated or reset connection rather than bad data
+// Timeouts and a done ctx are not, requesting the export again would not get further
+func isStreamDropped(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && !netErr.Timeout()
+}
+
+// exportEach calls the Raw Export API and calls fn for each decoded event
+// If the connection drops mid-stream the export is requested again and the events already delivered
+// are skipped by their $insert_id, so the $insert_id of every delivered event is kept in memory
+// The export is not resumed if a delivered event has no $insert_id or the export has a limit
+func (a *ApiClient) exportEach(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, options []ExportOption, fn func(*Event) error) error {
+	// counts, as the raw export can hold the same $insert_id more than once before mixpanel dedupes it
+	delivered := make(map[string]int)
+	resumable := limit == ExportNoLimit
+	deliver := func(e *Event) error {
+		if insertID, ok := e.Properties[propertyInsertID].(string); ok && insertID != "" {
+			delivered[insertID]++
+		} else {
+			resumable = false
+		}
+		return fn(e)
+	}
+
+	handle := deliver
+	for resumes := 0; ; resumes++ {
+		err := a.exportStream(ctx, fromDate, toDate, limit, event, where, options, handle)
+
+		var dropped *exportDroppedError
+		if !errors.As(err, &dropped) || !resumable || resumes >= exportMaxResumes {
+			return err
+		}
+
+		// the raw export is not guaranteed to be in time order, so the whole range is requested again
+		skip := make(map[string]int, len(delivered))
+		for insertID, count := range delivered {
+			skip[insertID] = count
+		}
+		handle = func(e *Event) error {
+			if insertID, ok := e.Properties[propertyInsertID].(string); ok && skip[insertID] > 0 {
+				skip[insertID]--
+				return nil
+			}
+			return deliver(e)
+		}
+	}
+}
+
+// exportStream makes a single Raw Export API request and calls fn for each decoded event
+func (a *ApiClient) exportStream(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, options []ExportOption, fn func(*Event) error) error {
+	httpResponse, err := a.doExportRequest(ctx, fromDate, toDate, limit, event, where, options)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+		// the export endpoint may gzip the response even when it wasn't requested
+		var body io.Reader = httpResponse.Body
+		if httpResponse.Header.Get(contentEncodingHeader) == "gzip" {
+			gzipReader, err := gzip.NewReader(httpResponse.Body)
+			if err != nil {
+				return fmt.Errorf("failed to create gzip reader: %w", err)
+			}
+			defer gzipReader.Close()
+			body = gzipReader
+		}
+
+		// decoding until io.EOF rather than using More catches a connection dropped between events
+		dec := json.NewDecoder(body)
+		if a.newExportConfig(options).useNumber {
+			dec.UseNumber()
+		}
+		for {
+			var e *Event
+			err := dec.Decode(&e)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				if isStreamDropped(ctx, err) {
+					return &exportDroppedError{err: err}
+				}
+				return fmt.Errorf("failed to decode event:%w", err)
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+}
+
+// doExportRequest calls the Raw Export API and returns the response for the caller to read
+func (a *ApiClient) doExportRequest(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, options []ExportOption, extra ...httpOptions) (*http.Response, error) {
+	config := a.newExportConfig(options)
+
 	query := url.Values{}
 	query.Add("from_date", fromDate.Format("2006-01-02"))
 	query.Add("to_date", toDate.Format("2006-01-02"))
@@ -34,34 +305,81 @@ func (a *ApiClient) Export(ctx context.Context, fromDate, toDate time.Time, limi
 		query.Add("where", where)
 	}
 
-	httpResponse, err := a.doRequestBody(
+	return a.doRequestBody(
 		ctx,
 		http.MethodGet,
 		a.dataEndpoint+exportUrl,
 		nil,
-		a.exportServiceAccount(), acceptPlainText(), addQueryParams(query),
+		append([]httpOptions{a.exportAuth(config), acceptPlainText(), addQueryParams(query)}, extra...)...,
 	)
+}
+
+// DownloadExportOptions filters and reports the progress of DownloadExportToFile
+type DownloadExportOptions struct {
+	Limit int
+	Event string
+	Where string
+	// Gzip requests a gzip compressed export which is written to the file still compressed
+	Gzip bool
+	// Progress is called with the total number of bytes written to the file so far
+	Progress func(written int64)
+}
+
+// DownloadExportToFile streams the Raw Export API response into the file at path without decoding the events
+// The export is written to a temporary file in the same directory which is renamed to path once complete,
+// so path is left untouched if the download fails, returns the number of bytes written
+func (a *ApiClient) DownloadExportToFile(ctx context.Context, path string, fromDate, toDate time.Time, opts DownloadExportOptions, options ...ExportOption) (int64, error) {
+	var extra []httpOptions
+	if opts.Gzip {
+		// setting the header ourselves stops net/http from transparently decompressing the body
+		extra = append(extra, acceptGzip())
+	}
+
+	httpResponse, err := a.doExportRequest(ctx, fromDate, toDate, opts.Limit, opts.Event, opts.Where, options, extra...)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer httpResponse.Body.Close()
 
-	switch httpResponse.StatusCode {
-	case http.StatusOK:
-		var results []*Event
+	if httpResponse.StatusCode != http.StatusOK {
+		return 0, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
 
-		dec := json.NewDecoder(httpResponse.Body)
-		for dec.More() {
-			var e *Event
-			err := dec.Decode(&e)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode event:%w", err)
-			}
-			results = append(results, e)
+	// download next to path so a failed download never leaves a partial file at path
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	written, err := io.Copy(&progressWriter{w: file, progress: opts.Progress}, httpResponse.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		if removeErr := os.Remove(file.Name()); removeErr != nil {
+			return 0, fmt.Errorf("failed to download export: %w, failed to remove %s: %v", err, file.Name(), removeErr)
 		}
-		return results, nil
+		return 0, fmt.Errorf("failed to download export: %w", err)
+	}
 
-	default:
-		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body)
+	return written, nil
+}
+
+// progressWriter reports the running total of bytes written
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	progress func(written int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.written)
 	}
+	return n, err
 }
@@ -1,13 +1,19 @@
 package mixpanel
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/jarcoal/httpmock"
@@ -95,4 +101,437 @@ func TestExport(t *testing.T) {
 		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
 		require.NoError(t, err)
 	})
+
+	t.Run("can map events while exporting", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			body := `
+			{"event":"old_name","properties":{"time":1684951135,"$insert_id":"insert-1"}}
+			{"event":"drop_me","properties":{"time":1684951136,"$insert_id":"insert-2"}}
+			{"event":"keep","properties":{"time":1684951137,"$insert_id":"insert-3"}}
+			`
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		events, err := mp.ExportMapped(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter, func(e *Event) (*Event, error) {
+			switch e.Name {
+			case "old_name":
+				e.Name = "new_name"
+			case "drop_me":
+				return nil, nil
+			}
+			return e, nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, events, 2)
+		require.Equal(t, "new_name", events[0].Name)
+		require.Equal(t, "keep", events[1].Name)
+	})
+
+	t.Run("can export grouped by user", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			body := `
+			{"event":"second","properties":{"time":1684951137,"distinct_id":"user-1"}}
+			{"event":"first","properties":{"time":1684951135,"distinct_id":"user-1"}}
+			{"event":"only","properties":{"time":1684951136,"distinct_id":"user-2"}}
+			{"event":"anonymous","properties":{"time":1684951136}}
+			`
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		users, err := mp.ExportByUser(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+
+		require.Len(t, users, 3)
+		require.Len(t, users["user-1"], 2)
+		require.Equal(t, "first", users["user-1"][0].Name)
+		require.Equal(t, "second", users["user-1"][1].Name)
+		require.Len(t, users["user-2"], 1)
+		require.Equal(t, "only", users["user-2"][0].Name)
+		require.Len(t, users[""], 1)
+		require.Equal(t, "anonymous", users[""][0].Name)
+	})
+
+	t.Run("mapper error aborts the export", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"event":"test","properties":{}}`)),
+			}, nil
+		})
+
+		mapperErr := errors.New("mapper failed")
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.ExportMapped(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter, func(e *Event) (*Event, error) {
+			return nil, mapperErr
+		})
+		require.ErrorIs(t, err, mapperErr)
+	})
+
+	t.Run("can export a gzip encoded response", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		body, err := gzipBody([]byte(`{"event":"test","properties":{"time":1684951135}}
+{"event":"test_2","properties":{"time":1684951332}}
+`))
+		require.NoError(t, err)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set(contentEncodingHeader, "gzip")
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       io.NopCloser(bytes.NewReader(body)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		events, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+
+		require.Len(t, events, 2)
+		require.Equal(t, "test", events[0].Name)
+		require.Equal(t, "test_2", events[1].Name)
+	})
+
+	t.Run("keeps large integer distinct ids", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, httpmock.NewStringResponder(http.StatusOK, `{"event":"test","properties":{"time":1684951135,"distinct_id":12345678901234567891}}`))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		events, err := mp.ExportWithOptions(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter, ExportUseNumber())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "12345678901234567891", fmt.Sprint(events[0].Properties["distinct_id"]))
+
+		byUser, err := mp.ExportByUser(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoEventFilter, ExportNoWhereFilter, ExportUseNumber())
+		require.NoError(t, err)
+		require.Contains(t, byUser, "12345678901234567891")
+	})
+
+	t.Run("groups numeric distinct ids by user", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, httpmock.NewStringResponder(http.StatusOK, `{"event":"a","properties":{"time":1684951135,"distinct_id":1234567}}
+		{"event":"b","properties":{"time":1684951136,"distinct_id":7654321}}
+		{"event":"c","properties":{"time":1684951137}}`))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		byUser, err := mp.ExportByUser(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+		require.Len(t, byUser, 3)
+		require.Equal(t, "a", byUser["1234567"][0].Name)
+		require.Equal(t, "b", byUser["7654321"][0].Name)
+		require.Equal(t, "c", byUser[""][0].Name)
+	})
+
+	t.Run("numbers are float64 by default", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, httpmock.NewStringResponder(http.StatusOK, `{"event":"test","properties":{"time":1684951135,"count":3}}`))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		events, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+		require.Equal(t, float64(3), events[0].Properties["count"])
+	})
+
+	t.Run("can override the project id", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		queryParams := url.Values{}
+		queryParams.Add("from_date", "2023-01-01")
+		queryParams.Add("to_date", "2023-01-02")
+		queryParams.Add("project_id", "118")
+
+		httpmock.RegisterMatcherResponderWithQuery(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), queryParams, httpmock.Matcher{}, httpmock.NewStringResponder(http.StatusOK, ""))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.ExportWithOptions(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter, ExportProjectID(118))
+		require.NoError(t, err)
+	})
+
+	t.Run("can omit the project id", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		queryParams := url.Values{}
+		queryParams.Add("from_date", "2023-01-01")
+		queryParams.Add("to_date", "2023-01-02")
+
+		httpmock.RegisterMatcherResponderWithQuery(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), queryParams, httpmock.Matcher{}, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("username:secret")), req.Header.Get("authorization"))
+			return httpmock.NewStringResponse(http.StatusOK, ""), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.ExportWithOptions(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter, ExportWithoutProjectID())
+		require.NoError(t, err)
+	})
+}
+
+func TestExportResume(t *testing.T) {
+	ctx := context.Background()
+
+	droppedBody := func(body string) io.ReadCloser {
+		return io.NopCloser(io.MultiReader(strings.NewReader(body), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	}
+
+	t.Run("resumes after the connection drops", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		var fromDates []string
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			fromDates = append(fromDates, req.URL.Query().Get("from_date"))
+			if len(fromDates) == 1 {
+				// b is later than a and z but arrives first
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: droppedBody(`{"event":"b","properties":{"time":1672747300,"$insert_id":"b"}}
+					{"event":"dup","properties":{"time":1672747200,"$insert_id":"dup"}}
+					{"event":"c","prop`),
+				}, nil
+			}
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"event":"b","properties":{"time":1672747300,"$insert_id":"b"}}
+			{"event":"dup","properties":{"time":1672747200,"$insert_id":"dup"}}
+			{"event":"z","properties":{"time":1672660800,"$insert_id":"z"}}
+			{"event":"dup","properties":{"time":1672747200,"$insert_id":"dup"}}
+			{"event":"a","properties":{"time":1672747200,"$insert_id":"a"}}
+			{"event":"c","properties":{"time":1672747400,"$insert_id":"c"}}`), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		events, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+
+		var names []string
+		for _, e := range events {
+			names = append(names, e.Name)
+		}
+		require.Equal(t, []string{"b", "dup", "z", "dup", "a", "c"}, names)
+		require.Equal(t, []string{"2023-01-01", "2023-01-01"}, fromDates)
+	})
+
+	t.Run("does not resume without insert ids", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       droppedBody(`{"event":"a","properties":{"time":1672747200}}`),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("gives up after repeated drops", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       droppedBody(`{"event":"a","properties":{"time":1672747200,"$insert_id":"a"}}`),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Equal(t, exportMaxResumes+1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("does not resume a limited export", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       droppedBody(`{"event":"a","properties":{"time":1672747200,"$insert_id":"a"}}`),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), 10, ExportNoEventFilter, ExportNoWhereFilter)
+		require.Error(t, err)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("does not resume after a timeout", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(io.MultiReader(
+					strings.NewReader(`{"event":"a","properties":{"time":1672747200,"$insert_id":"a"}}`),
+					iotest.ErrReader(os.ErrDeadlineExceeded),
+				)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("does not resume when the context is done", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       droppedBody(`{"event":"a","properties":{"time":1672747200,"$insert_id":"a"}}`),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.Export(ctx, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-05"), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.Error(t, err)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestDownloadExportToFile(t *testing.T) {
+	ctx := context.Background()
+	body := []byte(`{"event":"test","properties":{"time":1684951135}}
+{"event":"test_2","properties":{"time":1684951332}}
+`)
+
+	t.Run("writes the export to a file", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		queryParams := url.Values{}
+		queryParams.Add("from_date", "2023-01-01")
+		queryParams.Add("to_date", "2023-01-02")
+		queryParams.Add("event", "test")
+		queryParams.Add("project_id", "117")
+		httpmock.RegisterResponderWithQuery(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), queryParams, httpmock.NewBytesResponder(http.StatusOK, body))
+
+		var progress []int64
+		path := filepath.Join(t.TempDir(), "export.json")
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		written, err := mp.DownloadExportToFile(ctx, path, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), DownloadExportOptions{
+			Event: "test",
+			Progress: func(written int64) {
+				progress = append(progress, written)
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(len(body)), written)
+		require.NotEmpty(t, progress)
+		require.Equal(t, written, progress[len(progress)-1])
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, body, data)
+	})
+
+	t.Run("keeps a gzip response compressed", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		compressed, err := gzipBody(body)
+		require.NoError(t, err)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "gzip", req.Header.Get("Accept-Encoding"))
+
+			response := httpmock.NewBytesResponse(http.StatusOK, compressed)
+			response.Header.Set(contentEncodingHeader, "gzip")
+			return response, nil
+		})
+
+		path := filepath.Join(t.TempDir(), "export.json.gz")
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err = mp.DownloadExportToFile(ctx, path, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), DownloadExportOptions{Gzip: true})
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, compressed, data)
+	})
+
+	t.Run("no file on an unexpected status", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), httpmock.NewStringResponder(http.StatusUnauthorized, "unauthorized"))
+
+		path := filepath.Join(t.TempDir(), "export.json")
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.DownloadExportToFile(ctx, path, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), DownloadExportOptions{})
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("a failed download leaves the existing file", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(io.MultiReader(bytes.NewReader(body), iotest.ErrReader(io.ErrUnexpectedEOF))),
+			}, nil
+		})
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "export.json")
+		require.NoError(t, os.WriteFile(path, []byte("previous export"), 0o644))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.DownloadExportToFile(ctx, path, parseDate(t, "2023-01-01"), parseDate(t, "2023-01-02"), DownloadExportOptions{})
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "previous export", string(data))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
 }
@@ -12,6 +12,8 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type MpCompression int
@@ -27,23 +29,33 @@ var (
 	apiErrorStatus = 0
 )
 
+// DefaultResponseBodyLimit is the maximum number of bytes read from an error response body
+const DefaultResponseBodyLimit = 64 << 10
+
 type HttpError struct {
 	Status int
 	Body   string
+	// Truncated is true if Body was cut at the response body limit
+	Truncated bool
 }
 
-func newHttpError(statusCode int, data io.Reader) error {
-	body, err := io.ReadAll(data)
+// newHttpError reads at most limit bytes of data into the error body
+func newHttpError(statusCode int, data io.Reader, limit int64) error {
+	body, truncated, err := readLimitedBody(data, limit)
 	if err != nil {
 		return err
 	}
 	return HttpError{
-		Status: statusCode,
-		Body:   string(body),
+		Status:    statusCode,
+		Body:      body,
+		Truncated: truncated,
 	}
 }
 
 func (h HttpError) Error() string {
+	if h.Truncated {
+		return fmt.Sprintf("unexpected status code: %d, body (truncated): %s", h.Status, h.Body)
+	}
 	return fmt.Sprintf("unexpected status code: %d, body: %s", h.Status, h.Body)
 }
 
@@ -51,6 +63,24 @@ func (h HttpError) Unwrap() error {
 	return ErrUnexpectedStatus
 }
 
+// readLimitedBody reads at most limit bytes from data and reports if there was more
+// a limit of 0 or less uses DefaultResponseBodyLimit
+func readLimitedBody(data io.Reader, limit int64) (string, bool, error) {
+	if limit <= 0 {
+		limit = DefaultResponseBodyLimit
+	}
+
+	body, err := io.ReadAll(io.LimitReader(data, limit+1))
+	if err != nil {
+		return "", false, err
+	}
+
+	if int64(len(body)) > limit {
+		return string(body[:limit]), true, nil
+	}
+	return string(body), false, nil
+}
+
 type httpOptions func(req *http.Request)
 
 func gzipHeader() httpOptions {
@@ -89,6 +119,14 @@ func (m *ApiClient) useApiSecret() httpOptions {
 	}
 }
 
+func (m *ApiClient) serviceAccountAuth() httpOptions {
+	return func(req *http.Request) {
+		if m.serviceAccount != nil {
+			req.SetBasicAuth(m.serviceAccount.Username, m.serviceAccount.Secret)
+		}
+	}
+}
+
 // exportServiceAccount uses the service account if available and adds the query params
 // or falls back to apiSecret
 func (m *ApiClient) exportServiceAccount() httpOptions {
@@ -120,12 +158,56 @@ func addQueryParams(query url.Values) httpOptions {
 	}
 }
 
+// addDefaultQueryParams adds the client's default query params that the request doesn't set itself
+func (m *ApiClient) addDefaultQueryParams() httpOptions {
+	return func(req *http.Request) {
+		if len(m.defaultQueryParams) == 0 {
+			return
+		}
+
+		rQuery := req.URL.Query()
+		for key, values := range m.defaultQueryParams {
+			if _, ok := rQuery[key]; !ok {
+				rQuery[key] = values
+			}
+		}
+		req.URL.RawQuery = rQuery.Encode()
+	}
+}
+
+// consentHeader sets the ConsentHeader when WithConsent targets headers
+func (m *ApiClient) consentHeader() httpOptions {
+	return func(req *http.Request) {
+		if m.consentTarget&ConsentAsHeader != 0 {
+			req.Header.Set(ConsentHeader, m.consent)
+		}
+	}
+}
+
+func acceptGzip() httpOptions {
+	return func(req *http.Request) {
+		req.Header.Set(acceptEncodingHeader, "gzip")
+	}
+}
+
 func acceptPlainText() httpOptions {
 	return func(req *http.Request) {
 		req.Header.Set(acceptHeader, acceptPlainTextHeader)
 	}
 }
 
+// newTransportClient creates a http client with a copy of the default transport configured by options
+func newTransportClient(options []func(transport *http.Transport)) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	for _, o := range options {
+		o(transport)
+	}
+
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
 type debugHttpCalls struct {
 	writer io.Writer
 }
@@ -241,7 +323,108 @@ func (m *ApiClient) doRequestBody(
 		return nil, fmt.Errorf("failed to write debug_http call: %w", err)
 	}
 
-	return m.client.Do(request)
+	m.warnServiceAccountExpiry()
+
+	if m.requestSemaphore != nil {
+		select {
+		case m.requestSemaphore <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		if m.requestSemaphore != nil {
+			<-m.requestSemaphore
+		}
+		return nil, err
+	}
+
+	if m.rateLimitObserver != nil {
+		if info, ok := parseRateLimitInfo(response); ok {
+			m.rateLimitObserver(info)
+		}
+	}
+
+	if m.requestSemaphore != nil {
+		// the slot is held until the caller is done reading the response
+		response.Body = &releaseOnClose{
+			ReadCloser: response.Body,
+			release: func() {
+				<-m.requestSemaphore
+			},
+		}
+	}
+
+	return response, nil
+}
+
+// RateLimitInfo is the rate limit state mixpanel reported on a response
+type RateLimitInfo struct {
+	// Limit is the number of requests allowed in the current window, 0 if not reported
+	Limit int
+	// Remaining is the number of requests left in the current window, -1 if not reported
+	Remaining int
+	// Reset is when the current window resets, zero if not reported
+	Reset time.Time
+	// RetryAfter is how long to wait before retrying, from the Retry-After header
+	RetryAfter time.Duration
+}
+
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+)
+
+// parseRateLimitInfo reads the rate limit headers, ok is false if none are set
+func parseRateLimitInfo(response *http.Response) (RateLimitInfo, bool) {
+	info := RateLimitInfo{Remaining: -1}
+	found := false
+
+	if v, err := strconv.Atoi(response.Header.Get(rateLimitLimitHeader)); err == nil {
+		info.Limit = v
+		found = true
+	}
+	if v, err := strconv.Atoi(response.Header.Get(rateLimitRemainingHeader)); err == nil {
+		info.Remaining = v
+		found = true
+	}
+	if v, err := strconv.ParseInt(response.Header.Get(rateLimitResetHeader), 10, 64); err == nil {
+		// the reset is either a unix timestamp or a number of seconds from now
+		if v > maxResetDeltaSeconds {
+			info.Reset = time.Unix(v, 0)
+		} else {
+			info.Reset = time.Now().Add(time.Duration(v) * time.Second)
+		}
+		found = true
+	}
+	if v, err := strconv.Atoi(response.Header.Get(retryAfterHeader)); err == nil {
+		info.RetryAfter = time.Duration(v) * time.Second
+		found = true
+	} else if t, err := http.ParseTime(response.Header.Get(retryAfterHeader)); err == nil {
+		info.RetryAfter = time.Until(t)
+		found = true
+	}
+
+	return info, found
+}
+
+// maxResetDeltaSeconds tells a reset in seconds from now apart from a unix timestamp
+const maxResetDeltaSeconds = 365 * 24 * 60 * 60
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
 }
 
 func (m *ApiClient) doPeopleRequest(ctx context.Context, body any, u string) error {
@@ -256,6 +439,7 @@ func (m *ApiClient) doPeopleRequest(ctx context.Context, body any, u string) err
 		requestBody,
 		acceptPlainText(),
 		applicationJsonHeader(),
+		m.addDefaultQueryParams(),
 	)
 
 	if err != nil {
@@ -263,7 +447,7 @@ func (m *ApiClient) doPeopleRequest(ctx context.Context, body any, u string) err
 	}
 	defer response.Body.Close()
 
-	return processPeopleRequestResponse(response)
+	return processPeopleRequestResponse(response, m.responseBodyLimit)
 }
 
 func (m *ApiClient) doIdentifyRequest(ctx context.Context, body any, u string, option ...httpOptions) error {
@@ -273,6 +457,7 @@ func (m *ApiClient) doIdentifyRequest(ctx context.Context, body any, u string, o
 	}
 
 	requestOptions := append([]httpOptions{acceptPlainText(), applicationFormData()}, option...)
+	requestOptions = append(requestOptions, m.addDefaultQueryParams())
 	response, err := m.doRequestBody(
 		ctx,
 		http.MethodPost,
@@ -286,10 +471,10 @@ func (m *ApiClient) doIdentifyRequest(ctx context.Context, body any, u string, o
 	}
 	defer response.Body.Close()
 
-	return processPeopleRequestResponse(response)
+	return processPeopleRequestResponse(response, m.responseBodyLimit)
 }
 
-func processPeopleRequestResponse(response *http.Response) error {
+func processPeopleRequestResponse(response *http.Response, limit int64) error {
 	switch response.StatusCode {
 	case http.StatusOK:
 		var code int
@@ -301,11 +486,11 @@ func processPeopleRequestResponse(response *http.Response) error {
 		}
 		return nil
 	case http.StatusUnauthorized:
-		return fmt.Errorf("unauthorized: %w", newHttpError(response.StatusCode, response.Body))
+		return fmt.Errorf("unauthorized: %w", newHttpError(response.StatusCode, response.Body, limit))
 	case http.StatusForbidden:
-		return fmt.Errorf("forbidden: %w", newHttpError(response.StatusCode, response.Body))
+		return fmt.Errorf("forbidden: %w", newHttpError(response.StatusCode, response.Body, limit))
 	default:
-		return newHttpError(response.StatusCode, response.Body)
+		return newHttpError(response.StatusCode, response.Body, limit)
 	}
 }
 
@@ -318,10 +503,10 @@ func (a VerboseError) Error() string {
 	return a.ApiError
 }
 
-func parseVerboseApiError(jsonReader io.Reader) error {
-	var r VerboseError
-	if err := json.NewDecoder(jsonReader).Decode(&r); err != nil {
-		return fmt.Errorf("failed to json decode response body: %w", err)
+func parseVerboseApiError(response *http.Response, limit int64) error {
+	r, err := decodeVerboseResponse(response, limit)
+	if err != nil {
+		return err
 	}
 
 	if r.Status == apiErrorStatus {
@@ -330,3 +515,25 @@ func parseVerboseApiError(jsonReader io.Reader) error {
 
 	return nil
 }
+
+// decodeVerboseResponse decodes the verbose api response
+// if the body is not json (e.g. an html error page from a proxy) a HttpError with at most limit bytes of the raw body is returned
+func decodeVerboseResponse(response *http.Response, limit int64) (VerboseError, error) {
+	var r VerboseError
+
+	// a verbose response is small, the read is capped so a large error page is never fully buffered
+	readLimit := limit
+	if readLimit < DefaultResponseBodyLimit {
+		readLimit = DefaultResponseBodyLimit
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, readLimit+1))
+	if err != nil {
+		return r, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &r); err != nil {
+		return r, newHttpError(response.StatusCode, bytes.NewReader(body), limit)
+	}
+
+	return r, nil
+}
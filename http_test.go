@@ -1,15 +1,29 @@
 package mixpanel
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestVerboseError(t *testing.T) {
+	makeResponse := func(statusCode int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+
 	t.Run("no error occur", func(t *testing.T) {
 		verboseApiErrorJson := `
 	{
@@ -18,7 +32,7 @@ func TestVerboseError(t *testing.T) {
 	}
 	`
 
-		err := parseVerboseApiError(strings.NewReader(verboseApiErrorJson))
+		err := parseVerboseApiError(makeResponse(http.StatusOK, verboseApiErrorJson), DefaultResponseBodyLimit)
 		require.NoError(t, err)
 	})
 
@@ -30,16 +44,26 @@ func TestVerboseError(t *testing.T) {
 	}
 	`
 
-		err := parseVerboseApiError(strings.NewReader(verboseApiErrorJson))
+		err := parseVerboseApiError(makeResponse(http.StatusOK, verboseApiErrorJson), DefaultResponseBodyLimit)
 		verboseError := &VerboseError{}
 		require.ErrorAs(t, err, verboseError)
 		require.Equal(t, "data, missing or empty", verboseError.Error())
 	})
+
+	t.Run("non json body returns the raw body", func(t *testing.T) {
+		body := "<html><body>502 Bad Gateway</body></html>"
+
+		err := parseVerboseApiError(makeResponse(http.StatusBadGateway, body), DefaultResponseBodyLimit)
+		httpError := &HttpError{}
+		require.ErrorAs(t, err, httpError)
+		require.Equal(t, http.StatusBadGateway, httpError.Status)
+		require.Equal(t, body, httpError.Body)
+	})
 }
 
 func TestHttpError(t *testing.T) {
 	httpBody := strings.NewReader("http body")
-	err := newHttpError(http.StatusTeapot, httpBody)
+	err := newHttpError(http.StatusTeapot, httpBody, DefaultResponseBodyLimit)
 
 	genericHttpError := &HttpError{}
 	require.ErrorAs(t, err, genericHttpError)
@@ -49,6 +73,71 @@ func TestHttpError(t *testing.T) {
 	require.ErrorIs(t, err, ErrUnexpectedStatus)
 }
 
+func TestHttpErrorBodyLimit(t *testing.T) {
+	t.Run("default limit", func(t *testing.T) {
+		err := newHttpError(http.StatusBadGateway, strings.NewReader(strings.Repeat("a", DefaultResponseBodyLimit+10)), DefaultResponseBodyLimit)
+
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Len(t, httpErr.Body, DefaultResponseBodyLimit)
+		require.True(t, httpErr.Truncated)
+		require.Contains(t, httpErr.Error(), "truncated")
+	})
+
+	t.Run("client limit", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodGet, usDataEndpoint+exportUrl, httpmock.NewStringResponder(http.StatusBadGateway, strings.Repeat("a", 1000)))
+
+		mp := NewApiClient("token", WithResponseBodyLimit(100))
+		_, err := mp.Export(context.Background(), time.Now(), time.Now(), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, strings.Repeat("a", 100), httpErr.Body)
+		require.True(t, httpErr.Truncated)
+	})
+
+	t.Run("client limit on track", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, httpmock.NewStringResponder(http.StatusBadGateway, "<html>"+strings.Repeat("a", 1000)))
+
+		mp := NewApiClient("token", WithResponseBodyLimit(100))
+		err := mp.Track(context.Background(), []*Event{mp.NewEvent("some-event", "some-id", nil)})
+
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, "<html>"+strings.Repeat("a", 94), httpErr.Body)
+		require.True(t, httpErr.Truncated)
+	})
+
+	t.Run("zero and negative limits use the default", func(t *testing.T) {
+		for _, limit := range []int64{0, -1, -100} {
+			err := newHttpError(http.StatusBadGateway, strings.NewReader("http body"), limit)
+
+			httpErr := &HttpError{}
+			require.ErrorAs(t, err, httpErr)
+			require.Equal(t, "http body", httpErr.Body)
+			require.False(t, httpErr.Truncated)
+
+			mp := NewApiClient("token", WithResponseBodyLimit(limit))
+			require.Equal(t, int64(DefaultResponseBodyLimit), mp.responseBodyLimit)
+		}
+	})
+
+	t.Run("body under the limit is not truncated", func(t *testing.T) {
+		err := newHttpError(http.StatusBadGateway, strings.NewReader("http body"), DefaultResponseBodyLimit)
+
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, "http body", httpErr.Body)
+		require.False(t, httpErr.Truncated)
+	})
+}
+
 func TestProcessPeopleRequestResponse(t *testing.T) {
 	t.Run("http 200 but code 0", func(t *testing.T) {
 		response := &http.Response{
@@ -58,7 +147,7 @@ func TestProcessPeopleRequestResponse(t *testing.T) {
 			`)),
 		}
 
-		err := processPeopleRequestResponse(response)
+		err := processPeopleRequestResponse(response, DefaultResponseBodyLimit)
 		require.Error(t, err)
 	})
 
@@ -73,7 +162,7 @@ func TestProcessPeopleRequestResponse(t *testing.T) {
 			`)),
 		}
 
-		err := processPeopleRequestResponse(response)
+		err := processPeopleRequestResponse(response, DefaultResponseBodyLimit)
 		httpErr := &HttpError{}
 		require.ErrorAs(t, err, httpErr)
 		require.Equal(t, http.StatusUnauthorized, httpErr.Status)
@@ -90,7 +179,7 @@ func TestProcessPeopleRequestResponse(t *testing.T) {
 			`)),
 		}
 
-		err := processPeopleRequestResponse(response)
+		err := processPeopleRequestResponse(response, DefaultResponseBodyLimit)
 		httpErr := &HttpError{}
 		require.ErrorAs(t, err, httpErr)
 		require.Equal(t, http.StatusForbidden, httpErr.Status)
@@ -107,9 +196,119 @@ func TestProcessPeopleRequestResponse(t *testing.T) {
 			`)),
 		}
 
-		err := processPeopleRequestResponse(response)
+		err := processPeopleRequestResponse(response, DefaultResponseBodyLimit)
 		httpErr := &HttpError{}
 		require.ErrorAs(t, err, httpErr)
 		require.Equal(t, http.StatusTeapot, httpErr.Status)
 	})
 }
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	t.Run("requests are serialized", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var inFlight, maxInFlight int32
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, func(req *http.Request) (*http.Response, error) {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				seen := atomic.LoadInt32(&maxInFlight)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"error": "", "status": 1}`)),
+			}, nil
+		})
+
+		ctx := context.Background()
+		mp := NewApiClient("token", WithMaxConcurrentRequests(1))
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- mp.Track(ctx, []*Event{mp.NewEvent("some event", EmptyDistinctID, nil)})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, int32(1), maxInFlight)
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("waiting respects the context", func(t *testing.T) {
+		mp := NewApiClient("token", WithMaxConcurrentRequests(1))
+		mp.requestSemaphore <- struct{}{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := mp.Track(ctx, []*Event{mp.NewEvent("some event", EmptyDistinctID, nil)})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRateLimitObserver(t *testing.T) {
+	t.Run("parses the rate limit headers", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		reset := time.Now().Add(time.Minute).Truncate(time.Second)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			response := httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`)
+			response.Header.Set("X-RateLimit-Limit", "100")
+			response.Header.Set("X-RateLimit-Remaining", "7")
+			response.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			return response, nil
+		})
+
+		var infos []RateLimitInfo
+		mp := NewApiClient("token", WithRateLimitObserver(func(info RateLimitInfo) {
+			infos = append(infos, info)
+		}))
+		require.NoError(t, mp.Track(context.Background(), []*Event{mp.NewEvent("some-event", "some-id", nil)}))
+
+		require.Len(t, infos, 1)
+		require.Equal(t, 100, infos[0].Limit)
+		require.Equal(t, 7, infos[0].Remaining)
+		require.True(t, reset.Equal(infos[0].Reset))
+	})
+
+	t.Run("parses retry after and a relative reset", func(t *testing.T) {
+		response := &http.Response{Header: http.Header{}}
+		response.Header.Set("X-RateLimit-Reset", "30")
+		response.Header.Set("Retry-After", "5")
+
+		info, ok := parseRateLimitInfo(response)
+		require.True(t, ok)
+		require.Equal(t, -1, info.Remaining)
+		require.Equal(t, 5*time.Second, info.RetryAfter)
+		require.WithinDuration(t, time.Now().Add(30*time.Second), info.Reset, time.Second)
+	})
+
+	t.Run("not called without headers", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		called := false
+		mp := NewApiClient("token", WithRateLimitObserver(func(info RateLimitInfo) {
+			called = true
+		}))
+		require.NoError(t, mp.Track(context.Background(), []*Event{mp.NewEvent("some-event", "some-id", nil)}))
+		require.False(t, called)
+	})
+}
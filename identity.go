@@ -1,11 +1,15 @@
 package mixpanel
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 const (
 	identityEndpoint = "/track#create-identity"
 	aliasEndpoint    = "/track#identity-create-alias"
-	mergeEndpoint    = "/import"
+	// merge goes through the import endpoint, which authenticates with the api secret
+	mergeEndpoint = "/import#identity-merge"
 )
 
 type aliasPayload struct {
@@ -32,6 +36,56 @@ func (a *ApiClient) Alias(ctx context.Context, aliasID, distinctID string) error
 	return a.doIdentifyRequest(ctx, payload, aliasEndpoint)
 }
 
+type identifyPayload struct {
+	Event      string             `json:"event"`
+	Properties identifyProperties `json:"properties"`
+}
+
+type identifyProperties struct {
+	IdentifiedID string `json:"$identified_id"`
+	AnonID       string `json:"$anon_id"`
+	Token        string `json:"token"`
+}
+
+// IdentifyPair links an anonymous id to the id of the identified user
+type IdentifyPair struct {
+	IdentifiedID string
+	AnonID       string
+}
+
+// IdentifyBatch sends an $identify event for each pair, chunked into requests of at most MaxTrackEvents
+// https://developer.mixpanel.com/reference/create-identity
+func (a *ApiClient) IdentifyBatch(ctx context.Context, pairs []IdentifyPair) error {
+	if len(pairs) == 0 {
+		return fmt.Errorf("no identify pairs to send")
+	}
+
+	for start := 0; start < len(pairs); start += MaxTrackEvents {
+		end := start + MaxTrackEvents
+		if end > len(pairs) {
+			end = len(pairs)
+		}
+
+		payload := make([]*identifyPayload, 0, end-start)
+		for _, pair := range pairs[start:end] {
+			payload = append(payload, &identifyPayload{
+				Event: "$identify",
+				Properties: identifyProperties{
+					IdentifiedID: pair.IdentifiedID,
+					AnonID:       pair.AnonID,
+					Token:        a.token,
+				},
+			})
+		}
+
+		if err := a.doIdentifyRequest(ctx, payload, identityEndpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type mergePayload struct {
 	Event      string          `json:"event"`
 	Properties mergeProperties `json:"properties"`
@@ -42,14 +96,34 @@ type mergeProperties struct {
 }
 
 // https://developer.mixpanel.com/reference/identity-merge
-// must provide api secret
+// must provide api secret, the payload is sent as form data as the reference documents
 func (a *ApiClient) Merge(ctx context.Context, distinctID1, distinctID2 string) error {
-	payload := &mergePayload{
+	return a.doIdentifyRequest(ctx, newMergePayload(distinctID1, distinctID2), mergeEndpoint, a.useApiSecret())
+}
+
+// MergeBatch merges each pair of distinct ids in a single request
+// must provide api secret
+func (a *ApiClient) MergeBatch(ctx context.Context, pairs [][2]string) error {
+	if len(pairs) == 0 {
+		return fmt.Errorf("no distinct id pairs to merge")
+	}
+	if len(pairs) > MaxTrackEvents {
+		return fmt.Errorf("max merge pairs is %d", MaxTrackEvents)
+	}
+
+	payload := make([]*mergePayload, len(pairs))
+	for i, pair := range pairs {
+		payload[i] = newMergePayload(pair[0], pair[1])
+	}
+
+	return a.doIdentifyRequest(ctx, payload, mergeEndpoint, a.useApiSecret())
+}
+
+func newMergePayload(distinctID1, distinctID2 string) *mergePayload {
+	return &mergePayload{
 		Event: "$merge",
 		Properties: mergeProperties{
 			DistinctId: []string{distinctID1, distinctID2},
 		},
 	}
-
-	return a.doIdentifyRequest(ctx, payload, mergeEndpoint, a.useApiSecret())
 }
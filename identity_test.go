@@ -21,6 +21,7 @@ func setupIdentityEndpoint(t *testing.T, client *ApiClient, endpoint string, tes
 	httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, endpoint), func(req *http.Request) (*http.Response, error) {
 		require.Equal(t, req.Header.Get("content-type"), "application/x-www-form-urlencoded")
 		require.Equal(t, req.Header.Get("accept"), "text/plain")
+		testReq(req)
 
 		require.NoError(t, req.ParseForm())
 		data := req.Form.Get("data")
@@ -54,8 +55,9 @@ func TestAlias(t *testing.T) {
 func TestMerge(t *testing.T) {
 	ctx := context.Background()
 
-	mp := NewApiClient("token")
+	mp := NewApiClient("token", ApiSecret("api-secret"))
 	setupIdentityEndpoint(t, mp, mergeEndpoint, func(req *http.Request) {
+		require.Equal(t, "/import", req.URL.Path)
 		auth := req.Header.Get("authorization")
 		require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(mp.apiSecret+":")))
 	}, func(body io.Reader) {
@@ -71,3 +73,97 @@ func TestMerge(t *testing.T) {
 
 	require.NoError(t, mp.Merge(ctx, "distinct-id-1", "distinct-id-2"))
 }
+
+func TestMergeBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("merges all pairs in one request", func(t *testing.T) {
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupIdentityEndpoint(t, mp, mergeEndpoint, func(req *http.Request) {
+			auth := req.Header.Get("authorization")
+			require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(mp.apiSecret+":")))
+		}, func(body io.Reader) {
+			payload := []*mergePayload{}
+			require.NoError(t, json.NewDecoder(body).Decode(&payload))
+
+			require.Len(t, payload, 2)
+			require.Equal(t, "$merge", payload[0].Event)
+			require.Equal(t, []string{"distinct-id-1", "distinct-id-2"}, payload[0].Properties.DistinctId)
+			require.Equal(t, "$merge", payload[1].Event)
+			require.Equal(t, []string{"distinct-id-3", "distinct-id-4"}, payload[1].Properties.DistinctId)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("1")),
+		})
+
+		require.NoError(t, mp.MergeBatch(ctx, [][2]string{
+			{"distinct-id-1", "distinct-id-2"},
+			{"distinct-id-3", "distinct-id-4"},
+		}))
+	})
+
+	t.Run("no pairs", func(t *testing.T) {
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		require.Error(t, mp.MergeBatch(ctx, nil))
+	})
+}
+
+func TestIdentifyBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends all pairs in one request", func(t *testing.T) {
+		mp := NewApiClient("token")
+		calls := 0
+		setupIdentityEndpoint(t, mp, identityEndpoint, func(req *http.Request) {
+			calls++
+		}, func(body io.Reader) {
+			payload := []*identifyPayload{}
+			require.NoError(t, json.NewDecoder(body).Decode(&payload))
+
+			require.Len(t, payload, 2)
+			require.Equal(t, "$identify", payload[0].Event)
+			require.Equal(t, "user-1", payload[0].Properties.IdentifiedID)
+			require.Equal(t, "anon-1", payload[0].Properties.AnonID)
+			require.Equal(t, "token", payload[0].Properties.Token)
+			require.Equal(t, "$identify", payload[1].Event)
+			require.Equal(t, "user-2", payload[1].Properties.IdentifiedID)
+			require.Equal(t, "anon-2", payload[1].Properties.AnonID)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("1")),
+		})
+
+		require.NoError(t, mp.IdentifyBatch(ctx, []IdentifyPair{
+			{IdentifiedID: "user-1", AnonID: "anon-1"},
+			{IdentifiedID: "user-2", AnonID: "anon-2"},
+		}))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("chunks large batches", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		mp := NewApiClient("token")
+		var sizes []int
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+identityEndpoint, func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, req.ParseForm())
+			payload := []*identifyPayload{}
+			require.NoError(t, json.Unmarshal([]byte(req.Form.Get("data")), &payload))
+			sizes = append(sizes, len(payload))
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		pairs := make([]IdentifyPair, MaxTrackEvents+1)
+		for i := range pairs {
+			pairs[i] = IdentifyPair{IdentifiedID: fmt.Sprintf("user-%d", i), AnonID: fmt.Sprintf("anon-%d", i)}
+		}
+		require.NoError(t, mp.IdentifyBatch(ctx, pairs))
+		require.Equal(t, []int{MaxTrackEvents, 1}, sizes)
+	})
+
+	t.Run("no pairs", func(t *testing.T) {
+		mp := NewApiClient("token")
+		require.Error(t, mp.IdentifyBatch(ctx, nil))
+	})
+}
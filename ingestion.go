@@ -2,12 +2,19 @@ package mixpanel
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -47,17 +54,88 @@ const (
 // Track calls the Track endpoint
 // For server side we recommend Import func
 // more info here: https://developer.mixpanel.com/reference/track-event#when-to-use-track-vs-import
+// Events are sent in the order they are given
 func (m *ApiClient) Track(ctx context.Context, events []*Event) error {
+	_, err := m.TrackWithResult(ctx, events)
+	return err
+}
+
+// TrackOne calls Track with a single event
+func (m *ApiClient) TrackOne(ctx context.Context, event *Event) error {
+	return m.Track(ctx, []*Event{event})
+}
+
+// TrackResult is the parsed verbose response of the Track endpoint
+type TrackResult struct {
+	// Status is the verbose status returned by mixpanel, 1 on success and 0 on failure
+	Status int
+	// ApiError is the error message returned by mixpanel, empty on success
+	ApiError string
+	// EventsSent is the number of events sent in the request
+	EventsSent int
+}
+
+// TrackWithResult calls the Track endpoint and returns the parsed response
+// On an api failure both the result and a VerboseError are returned
+func (m *ApiClient) TrackWithResult(ctx context.Context, events []*Event) (*TrackResult, error) {
 	if len(events) > MaxTrackEvents {
-		return fmt.Errorf("max track events is %d", MaxTrackEvents)
+		return nil, fmt.Errorf("max track events is %d", MaxTrackEvents)
+	}
+
+	events, err := m.prepareEvents(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return &TrackResult{Status: 1}, nil
+	}
+	if err := m.runBeforeSend(ctx, m.apiEndpoint+trackURL, events); err != nil {
+		return nil, err
 	}
 
+	result, err := m.sendTrack(ctx, events)
+	if err != nil {
+		m.sendToDeadLetter(ctx, events, err)
+	}
+	return result, err
+}
+
+func (m *ApiClient) sendTrack(ctx context.Context, events []*Event) (*TrackResult, error) {
 	query := url.Values{}
 	query.Add("verbose", "1")
 
-	requestBody, err := makeRequestBody(events, jsonPayload, None)
+	response, err := m.doTrackRequest(ctx, events, query)
 	if err != nil {
-		return fmt.Errorf("failed to create request body: %w", err)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	verbose, err := decodeVerboseResponse(response, m.responseBodyLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TrackResult{
+		Status:     verbose.Status,
+		ApiError:   verbose.ApiError,
+		EventsSent: len(events),
+	}
+	if verbose.Status == apiErrorStatus {
+		return result, verbose
+	}
+
+	return result, nil
+}
+
+func (m *ApiClient) doTrackRequest(ctx context.Context, events []*Event, query url.Values) (*http.Response, error) {
+	requestBody, err := makeRequestBody(events, jsonPayload, m.trackCompression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	httpOptions := []httpOptions{addQueryParams(query), acceptPlainText(), applicationJsonHeader(), m.addDefaultQueryParams(), m.consentHeader()}
+	if m.trackCompression == Gzip {
+		httpOptions = append(httpOptions, gzipHeader())
 	}
 
 	response, err := m.doRequestBody(
@@ -65,14 +143,282 @@ func (m *ApiClient) Track(ctx context.Context, events []*Event) error {
 		http.MethodPost,
 		m.apiEndpoint+trackURL,
 		requestBody,
-		addQueryParams(query), acceptPlainText(), applicationJsonHeader(),
+		httpOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to track event: %w", err)
+	}
+	return response, nil
+}
+
+// TrackIsolated calls the Track endpoint in strict mode so mixpanel validates each event
+// If some events fail validation and mixpanel imported none of the batch, it is sent again without them
+// When mixpanel already imported the valid events they are not sent again
+// The events mixpanel rejected are returned
+func (m *ApiClient) TrackIsolated(ctx context.Context, events []*Event) ([]*Event, error) {
+	if len(events) > MaxTrackEvents {
+		return nil, fmt.Errorf("max track events is %d", MaxTrackEvents)
+	}
+
+	events, err := m.prepareEvents(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if err := m.runBeforeSend(ctx, m.apiEndpoint+trackURL, events); err != nil {
+		return nil, err
+	}
+
+	failed, imported, err := m.sendTrackStrict(ctx, events)
+	if err != nil {
+		m.sendToDeadLetter(ctx, events, err)
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	rejected := make(map[int]bool, len(failed))
+	for _, f := range failed {
+		rejected[f.Index] = true
+	}
+
+	var dropped, remaining []*Event
+	for i, e := range events {
+		if rejected[i] {
+			dropped = append(dropped, e)
+		} else {
+			remaining = append(remaining, e)
+		}
+	}
+	// the valid events were ingested with the rejected ones, sending them again would duplicate them
+	if len(remaining) == 0 || imported > 0 {
+		return dropped, nil
+	}
+
+	failed, _, err = m.sendTrackStrict(ctx, remaining)
+	if err == nil && len(failed) > 0 {
+		err = fmt.Errorf("%d events failed validation after removing the rejected events", len(failed))
+	}
+	if err != nil {
+		m.sendToDeadLetter(ctx, remaining, err)
+		return dropped, err
+	}
+
+	return dropped, nil
+}
+
+// sendTrackStrict sends the events with strict validation and returns the records mixpanel rejected
+// along with the number of records mixpanel imported from a partially rejected batch
+func (m *ApiClient) sendTrackStrict(ctx context.Context, events []*Event) ([]ImportFailedRecords, int, error) {
+	query := url.Values{}
+	query.Add("verbose", "1")
+	query.Add("strict", "1")
+
+	response, err := m.doTrackRequest(ctx, events, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusBadRequest {
+		var g ImportFailedValidationError
+		if err := json.NewDecoder(response.Body).Decode(&g); err != nil {
+			return nil, 0, fmt.Errorf("failed to json decode response body: %w", err)
+		}
+		if len(g.FailedImportRecords) == 0 {
+			return nil, 0, g
+		}
+		return g.FailedImportRecords, g.NumRecordsImported, nil
+	}
+
+	if err := parseVerboseApiError(response, m.responseBodyLimit); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, nil
+}
+
+// runBeforeSend calls the before send func, if one is configured
+func (m *ApiClient) runBeforeSend(ctx context.Context, endpoint string, events []*Event) error {
+	if m.beforeSend == nil {
+		return nil
+	}
+	if err := m.beforeSend(ctx, endpoint, events); err != nil {
+		return fmt.Errorf("request aborted before send: %w", err)
+	}
+	return nil
+}
+
+// sendToDeadLetter hands a batch that failed to send to the dead letter func, if one is configured
+func (m *ApiClient) sendToDeadLetter(ctx context.Context, events []*Event, cause error) {
+	if m.deadLetter != nil {
+		m.deadLetter(ctx, events, cause)
+	}
+}
+
+// prepareEvents runs the client's event hooks before the events are sent
+// Events dropped by the event filters are not part of the returned slice
+func (m *ApiClient) prepareEvents(ctx context.Context, events []*Event) ([]*Event, error) {
+	if err := checkNilEvents(events); err != nil {
+		return nil, err
+	}
+
+	prepared := make([]*Event, 0, len(events))
+	for _, e := range events {
+		if !m.keepEvent(e) {
+			atomic.AddUint64(&m.droppedEvents, 1)
+			continue
+		}
+
+		for _, enrich := range m.eventEnrichers {
+			enrich(ctx, e)
+		}
+		m.addConsentProperty(e)
+		m.formatTimeProperties(e.Properties)
+		prepared = append(prepared, e)
+	}
+
+	if err := m.limitPropertyValues(prepared); err != nil {
+		return nil, err
+	}
+	if err := m.handleNonFiniteFloats(prepared); err != nil {
+		return nil, err
+	}
+	if err := m.validateSchema(prepared); err != nil {
+		return nil, err
+	}
+
+	return prepared, nil
+}
+
+func checkNilEvents(events []*Event) error {
+	for i, e := range events {
+		if e == nil {
+			return fmt.Errorf("event %d is nil", i)
+		}
+	}
+	return nil
+}
+
+func (m *ApiClient) addConsentProperty(e *Event) {
+	if m.consentTarget&ConsentAsProperty == 0 {
+		return
+	}
+	if e.Properties == nil {
+		e.Properties = make(map[string]any)
+	}
+	if _, ok := e.Properties[ConsentProperty]; !ok {
+		e.Properties[ConsentProperty] = m.consent
+	}
+}
+
+func (m *ApiClient) keepEvent(e *Event) bool {
+	for _, filter := range m.eventFilters {
+		if !filter(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// DroppedEvents returns the number of events dropped by the event filters
+func (m *ApiClient) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&m.droppedEvents)
+}
+
+// EstimateBatchSize returns the size in bytes of the request body for events without sending it
+// compressed is the gzipped size when compression is Gzip, otherwise it equals uncompressed
+func EstimateBatchSize(events []*Event, compression MpCompression) (uncompressed, compressed int, err error) {
+	jsonData, err := json.Marshal(events)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal events: %w", err)
+	}
+
+	body, err := requestBodyJsonCompress(jsonData, compression)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return len(jsonData), body.Len(), nil
+}
+
+// TrackPixel returns the legacy GET tracking url for the event with the base64 encoded event in the data param
+// The url responds with a 1x1 gif so it can be embedded as an image src in environments that can't POST
+// https://developer.mixpanel.com/reference/track-event
+// The url is built from the event as is, the client's event hooks (filters, enrichers, WithConsent,
+// property limits, WithSchemaValidation and WithBeforeSend) are not run as the client never sends it
+func (m *ApiClient) TrackPixel(ctx context.Context, event *Event) (string, error) {
+	query, err := trackPixelQuery(event)
+	if err != nil {
+		return "", err
+	}
+	query.Add("img", "1")
+
+	return m.apiEndpoint + trackURL + "?" + query.Encode(), nil
+}
+
+// SendTrackPixel tracks the event using the legacy GET form of the Track endpoint
+// The event goes through the same event hooks as Track
+func (m *ApiClient) SendTrackPixel(ctx context.Context, event *Event) error {
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	events, err := m.prepareEvents(ctx, []*Event{event})
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := m.runBeforeSend(ctx, m.apiEndpoint+trackURL, events); err != nil {
+		return err
+	}
+
+	if err := m.sendTrackPixel(ctx, events[0]); err != nil {
+		m.sendToDeadLetter(ctx, events, err)
+		return err
+	}
+	return nil
+}
+
+func (m *ApiClient) sendTrackPixel(ctx context.Context, event *Event) error {
+	query, err := trackPixelQuery(event)
+	if err != nil {
+		return err
+	}
+	query.Add("verbose", "1")
+
+	response, err := m.doRequestBody(
+		ctx,
+		http.MethodGet,
+		m.apiEndpoint+trackURL,
+		nil,
+		addQueryParams(query), acceptPlainText(), m.addDefaultQueryParams(), m.consentHeader(),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to track event: %w", err)
 	}
 	defer response.Body.Close()
 
-	return parseVerboseApiError(response.Body)
+	return parseVerboseApiError(response, m.responseBodyLimit)
+}
+
+func trackPixelQuery(event *Event) (url.Values, error) {
+	if event == nil {
+		return nil, fmt.Errorf("event is nil")
+	}
+
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := url.Values{}
+	query.Add("data", base64.StdEncoding.EncodeToString(jsonData))
+	return query, nil
 }
 
 type ImportFailedValidationError struct {
@@ -88,6 +434,9 @@ type ImportFailedRecords struct {
 	InsertID string `json:"insert_id"`
 	Field    string `json:"field"`
 	Message  string `json:"message"`
+	// Event is the rejected event, Index is its position in the batch that was sent
+	// which can differ from the slice passed to Import when event filters drop events
+	Event *Event `json:"-"`
 }
 
 func (e ImportFailedValidationError) Error() string {
@@ -97,6 +446,12 @@ func (e ImportFailedValidationError) Error() string {
 type ImportOptions struct {
 	Strict      bool
 	Compression MpCompression
+	// InsertIDFromProperty copies the value of the named property into $insert_id
+	// events that already have an insert id are left untouched
+	InsertIDFromProperty string
+	// BatchSize is the number of events per request for ImportChannel and ImportChunked
+	// between 1 and MaxImportEvents, zero means MaxImportEvents
+	BatchSize int
 }
 
 var ImportOptionsRecommend = ImportOptions{
@@ -104,12 +459,70 @@ var ImportOptionsRecommend = ImportOptions{
 	Compression: Gzip,
 }
 
+// withDefaultImportOptions replaces zero value options with the client's default import options
+// BatchSize is not part of the zero check, a call that only sets it still gets the defaults
+func (a *ApiClient) withDefaultImportOptions(options ImportOptions) ImportOptions {
+	batchSize := options.BatchSize
+	options.BatchSize = 0
+	if options != (ImportOptions{}) || a.defaultImportOptions == nil {
+		options.BatchSize = batchSize
+		return options
+	}
+
+	defaults := *a.defaultImportOptions
+	if batchSize != 0 {
+		defaults.BatchSize = batchSize
+	}
+	return defaults
+}
+
+// batchSize validates the BatchSize option
+func (o ImportOptions) batchSize() (int, error) {
+	if o.BatchSize == 0 {
+		return MaxImportEvents, nil
+	}
+	if o.BatchSize < 1 || o.BatchSize > MaxImportEvents {
+		return 0, fmt.Errorf("import batch size must be between 1 and %d", MaxImportEvents)
+	}
+	return o.BatchSize, nil
+}
+
 type ImportSuccess struct {
 	Code               int         `json:"code"`
 	NumRecordsImported int         `json:"num_records_imported"`
 	Status             interface{} `json:"status"`
 }
 
+// StatusText is the status field as text, e.g. "OK" or "1"
+func (s ImportSuccess) StatusText() string {
+	switch status := s.Status.(type) {
+	case nil:
+		return ""
+	case string:
+		return status
+	case float64:
+		return strconv.FormatFloat(status, 'f', -1, 64)
+	default:
+		return fmt.Sprint(status)
+	}
+}
+
+// IsFullSuccess reports if the code and status of the response both signal success
+// A 200 response can still carry a failure code or status
+func (s ImportSuccess) IsFullSuccess() bool {
+	// no code means no request was sent, e.g. every event was filtered
+	if s.Code != 0 && s.Code != http.StatusOK {
+		return false
+	}
+
+	switch strings.ToLower(s.StatusText()) {
+	case "", "ok", "1":
+		return true
+	default:
+		return false
+	}
+}
+
 type ImportRateLimitError struct {
 	ImportGenericError
 }
@@ -126,12 +539,55 @@ func (e ImportGenericError) Error() string {
 
 // Import calls the Import api
 // https://developer.mixpanel.com/reference/import-events
+// Event times in seconds are normalized to milliseconds before sending
+// Events are sent in the order they are given
 // Need to provide project id a service account, project token or api secret to the client
 func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportOptions) (*ImportSuccess, error) {
+	success, _, err := a.importEvents(ctx, events, options)
+	return success, err
+}
+
+// importEvents is Import that also returns how many events were sent after the event filters
+func (a *ApiClient) importEvents(ctx context.Context, events []*Event, options ImportOptions) (*ImportSuccess, int, error) {
+	options = a.withDefaultImportOptions(options)
 	if len(events) > MaxImportEvents {
-		return nil, fmt.Errorf("max import events is %d", MaxImportEvents)
+		return nil, 0, fmt.Errorf("max import events is %d", MaxImportEvents)
+	}
+	if err := checkNilEvents(events); err != nil {
+		return nil, 0, err
+	}
+
+	for _, e := range events {
+		e.NormalizeTime()
+		if options.InsertIDFromProperty != "" {
+			e.insertIDFromProperty(options.InsertIDFromProperty)
+		}
 	}
 
+	events, err := a.prepareEvents(ctx, events)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(events) == 0 {
+		return &ImportSuccess{}, 0, nil
+	}
+	if err := a.runBeforeSend(ctx, a.apiEndpoint+importURL, events); err != nil {
+		return nil, 0, err
+	}
+
+	success, err := a.sendImport(ctx, events, options)
+	if err != nil {
+		a.sendToDeadLetter(ctx, events, err)
+	}
+	return success, len(events), err
+}
+
+// ImportOne calls Import with a single event
+func (a *ApiClient) ImportOne(ctx context.Context, event *Event, options ImportOptions) (*ImportSuccess, error) {
+	return a.Import(ctx, []*Event{event}, options)
+}
+
+func (a *ApiClient) sendImport(ctx context.Context, events []*Event, options ImportOptions) (*ImportSuccess, error) {
 	values := url.Values{}
 	if options.Strict {
 		values.Add("strict", "1")
@@ -149,7 +605,7 @@ func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportO
 		return nil, fmt.Errorf("failed to create request body: %w", err)
 	}
 
-	httpOptions := []httpOptions{applicationJsonHeader(), addQueryParams(values), acceptJson(), a.importAuthOptions()}
+	httpOptions := []httpOptions{applicationJsonHeader(), addQueryParams(values), acceptJson(), a.importAuthOptions(), a.addDefaultQueryParams(), a.consentHeader()}
 	if options.Compression == Gzip {
 		httpOptions = append(httpOptions, gzipHeader())
 	}
@@ -178,6 +634,11 @@ func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportO
 		if err := json.NewDecoder(httpResponse.Body).Decode(&g); err != nil {
 			return nil, fmt.Errorf("failed to json decode response body: %w", err)
 		}
+		for i, record := range g.FailedImportRecords {
+			if record.Index >= 0 && record.Index < len(events) {
+				g.FailedImportRecords[i].Event = events[record.Index]
+			}
+		}
 		return nil, g
 	case http.StatusUnauthorized, http.StatusRequestEntityTooLarge:
 		var g ImportGenericError
@@ -196,6 +657,128 @@ func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportO
 	}
 }
 
+// ImportChannel reads events from ch and imports them in batches of options.BatchSize
+// Each batch is gzipped and sent as soon as it fills; the last partial batch is sent when ch is closed
+// Stops on the first failed batch or when ctx is done, returning the records imported so far with the error
+func (a *ApiClient) ImportChannel(ctx context.Context, ch <-chan *Event, options ImportOptions) (*ImportSuccess, error) {
+	options = a.withDefaultImportOptions(options)
+	options.Compression = Gzip
+	batchSize, err := options.batchSize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportSuccess{}
+	batch := make([]*Event, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		success, err := a.Import(ctx, batch, options)
+		if err != nil {
+			return err
+		}
+		result.Code = success.Code
+		result.Status = success.Status
+		result.NumRecordsImported += success.NumRecordsImported
+
+		batch = make([]*Event, 0, batchSize)
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case e, ok := <-ch:
+			if !ok {
+				if err := flush(); err != nil {
+					return result, err
+				}
+				return result, nil
+			}
+
+			batch = append(batch, e)
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+}
+
+// DetailedImportResult is the per chunk breakdown of ImportChunked
+type DetailedImportResult struct {
+	// NumRecordsImported is the total over all chunks
+	NumRecordsImported int
+	Chunks             []ImportChunkResult
+}
+
+// ImportChunkResult is the outcome of a single chunk of ImportChunked
+type ImportChunkResult struct {
+	// Index is the position of the chunk, starting at 0
+	Index int
+	// EventsSent is the number of events of the chunk sent to mixpanel, events dropped by the event filters are not counted
+	EventsSent         int
+	NumRecordsImported int
+	// Err is the error returned when importing the chunk, nil on success
+	Err error
+}
+
+// ImportChunked imports events in chunks of options.BatchSize and reports the outcome of each chunk
+// A failed chunk doesn't stop the later ones, the returned error wraps the first failure
+// Stops early when ctx is done or mixpanel rate limits the import, the chunks not attempted are not in the result
+func (a *ApiClient) ImportChunked(ctx context.Context, events []*Event, options ImportOptions) (*DetailedImportResult, error) {
+	options = a.withDefaultImportOptions(options)
+	batchSize, err := options.batchSize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DetailedImportResult{}
+	var failed int
+	var firstErr error
+	for start := 0; start < len(events); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		end := start + batchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		chunk := ImportChunkResult{
+			Index: len(result.Chunks),
+		}
+		success, sent, err := a.importEvents(ctx, events[start:end], options)
+		chunk.EventsSent = sent
+		if err != nil {
+			chunk.Err = err
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			chunk.NumRecordsImported = success.NumRecordsImported
+			result.NumRecordsImported += success.NumRecordsImported
+		}
+		result.Chunks = append(result.Chunks, chunk)
+
+		// every further chunk would be rate limited too
+		if errors.As(err, &ImportRateLimitError{}) {
+			break
+		}
+	}
+
+	if firstErr != nil {
+		return result, fmt.Errorf("%d of %d import chunks failed: %w", failed, len(result.Chunks), firstErr)
+	}
+	return result, nil
+}
+
 type PeopleReveredProperties string
 
 const (
@@ -220,6 +803,8 @@ type PeopleProperties struct {
 	DistinctID   string
 	Properties   map[string]any
 	UseRequestIp bool
+
+	geoLookupDisabled bool
 }
 
 func NewPeopleProperties(distinctID string, properties map[string]any) *PeopleProperties {
@@ -234,10 +819,77 @@ func NewPeopleProperties(distinctID string, properties map[string]any) *PeoplePr
 	}
 }
 
+// NewServerPeopleProperties creates people properties for server side updates
+// Mixpanel never geo looks up the ip for these, even with SetIp or UseRequestIp
+func NewServerPeopleProperties(distinctID string, properties map[string]any) *PeopleProperties {
+	p := NewPeopleProperties(distinctID, properties)
+	p.geoLookupDisabled = true
+	return p
+}
+
 func (p *PeopleProperties) SetReservedProperty(property PeopleReveredProperties, value any) {
 	p.Properties[string(property)] = value
 }
 
+// MergePolicy decides what PeopleProperties.Merge does with properties that are already set
+type MergePolicy int
+
+const (
+	// MergeOverwrite replaces existing values
+	MergeOverwrite MergePolicy = iota
+	// MergeKeepExisting keeps existing values
+	MergeKeepExisting
+	// MergeError returns a MergeConflictError and leaves the properties untouched
+	MergeError
+)
+
+// MergeConflictError is returned by PeopleProperties.Merge with MergeError when properties already have a different value
+type MergeConflictError struct {
+	Properties []string
+}
+
+func (e MergeConflictError) Error() string {
+	return fmt.Sprintf("properties already set: %s", strings.Join(e.Properties, ", "))
+}
+
+// Merge copies props into the profile properties, policy decides what happens to properties that are already set
+// Properties set to an equal value are not a conflict
+func (p *PeopleProperties) Merge(props map[string]any, policy MergePolicy) error {
+	if p.Properties == nil {
+		p.Properties = make(map[string]any, len(props))
+	}
+
+	if policy == MergeError {
+		var conflicts []string
+		for key, value := range props {
+			if existing, ok := p.Properties[key]; ok && !reflect.DeepEqual(existing, value) {
+				conflicts = append(conflicts, key)
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			return MergeConflictError{Properties: conflicts}
+		}
+	}
+
+	for key, value := range props {
+		if _, ok := p.Properties[key]; ok && policy == MergeKeepExisting {
+			continue
+		}
+		p.Properties[key] = value
+	}
+	return nil
+}
+
+// peopleDateFormat is the date format mixpanel expects for date properties on profiles
+const peopleDateFormat = "2006-01-02T15:04:05"
+
+// SetCreated sets the $created reserved property in the format mixpanel expects
+// the time is converted to UTC
+func (p *PeopleProperties) SetCreated(created time.Time) {
+	p.SetReservedProperty(PeopleCreatedProperty, created.UTC().Format(peopleDateFormat))
+}
+
 type PeopleIpOptions = func(peopleProperties *PeopleProperties)
 
 func UseRequestIp() PeopleIpOptions {
@@ -260,21 +912,57 @@ func (p *PeopleProperties) SetIp(ip net.IP, options ...PeopleIpOptions) {
 	}
 }
 
+// noGeoLookupIp tells mixpanel not to geo lookup the profile from the request ip
+// Only PeopleSet and PeopleSetOnce can geo locate a profile, every other profile update sends noGeoLookupIp
+const noGeoLookupIp = "0"
+
 // Note: if no ip is provided, we will not track by default
 func (p *PeopleProperties) shouldGeoLookupIp() string {
+	if p.geoLookupDisabled {
+		return noGeoLookupIp
+	}
 	if p.UseRequestIp {
 		return ""
 	}
 
 	v, ok := p.Properties[string(PeopleGeolocationByIpProperty)]
 	if !ok {
-		return "0"
+		return noGeoLookupIp
 	}
 	if s, ok := v.(string); ok {
 		// if ip is provided, passing it to mixpanel will cause it to be geo lookup
 		return s
 	}
-	return "0"
+	return noGeoLookupIp
+}
+
+// DuplicateDistinctIDError is returned when a people batch contains the same distinct id more than once
+type DuplicateDistinctIDError struct {
+	DistinctIDs []string
+}
+
+func (e DuplicateDistinctIDError) Error() string {
+	return fmt.Sprintf("duplicate distinct ids in batch: %s", strings.Join(e.DistinctIDs, ", "))
+}
+
+func (a *ApiClient) checkDuplicateDistinctIDs(people []*PeopleProperties) error {
+	if !a.rejectDuplicateDistinctIDs {
+		return nil
+	}
+
+	seen := make(map[string]int, len(people))
+	var duplicates []string
+	for _, p := range people {
+		seen[p.DistinctID]++
+		if seen[p.DistinctID] == 2 {
+			duplicates = append(duplicates, p.DistinctID)
+		}
+	}
+
+	if len(duplicates) > 0 {
+		return DuplicateDistinctIDError{DistinctIDs: duplicates}
+	}
+	return nil
 }
 
 type peopleSetPayload struct {
@@ -290,9 +978,13 @@ func (a *ApiClient) PeopleSet(ctx context.Context, people []*PeopleProperties) e
 	if len(people) > MaxPeopleEvents {
 		return fmt.Errorf("max people events is %d", MaxPeopleEvents)
 	}
+	if err := a.checkDuplicateDistinctIDs(people); err != nil {
+		return err
+	}
 
 	payloads := make([]peopleSetPayload, len(people))
 	for i, p := range people {
+		a.formatTimeProperties(p.Properties)
 		payloads[i] = peopleSetPayload{
 			Token:      a.token,
 			DistinctID: p.DistinctID,
@@ -317,9 +1009,13 @@ func (a *ApiClient) PeopleSetOnce(ctx context.Context, people []*PeoplePropertie
 	if len(people) > MaxPeopleEvents {
 		return fmt.Errorf("max people events is %d", MaxPeopleEvents)
 	}
+	if err := a.checkDuplicateDistinctIDs(people); err != nil {
+		return err
+	}
 
 	payloads := make([]peopleSetOncePayload, len(people))
 	for i, p := range people {
+		a.formatTimeProperties(p.Properties)
 		payloads[i] = peopleSetOncePayload{
 			Token:      a.token,
 			DistinctID: p.DistinctID,
@@ -334,6 +1030,7 @@ type peopleNumericalAddPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Add        map[string]int `json:"$add"`
+	IP         string         `json:"$ip"`
 }
 
 // PeopleIncrement calls the User Increment Numerical Property API
@@ -343,6 +1040,7 @@ func (a *ApiClient) PeopleIncrement(ctx context.Context, distinctID string, add
 		{
 			Token:      a.token,
 			DistinctID: distinctID,
+			IP:         noGeoLookupIp,
 			Add:        add,
 		},
 	}
@@ -353,6 +1051,7 @@ type peopleUnionPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Union      map[string]any `json:"$union"`
+	IP         string         `json:"$ip"`
 }
 
 // PeopleUnionProperty calls User Union To List Property API
@@ -362,6 +1061,7 @@ func (a *ApiClient) PeopleUnionProperty(ctx context.Context, distinctID string,
 		{
 			Token:      a.token,
 			DistinctID: distinctID,
+			IP:         noGeoLookupIp,
 			Union:      union,
 		},
 	}
@@ -372,6 +1072,7 @@ type peopleAppendListPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Append     map[string]any `json:"$append"`
+	IP         string         `json:"$ip"`
 }
 
 // PeopleAppend calls the Increment Numerical Property
@@ -381,6 +1082,7 @@ func (a *ApiClient) PeopleAppendListProperty(ctx context.Context, distinctID str
 		{
 			Token:      a.token,
 			DistinctID: distinctID,
+			IP:         noGeoLookupIp,
 			Append:     append,
 		},
 	}
@@ -391,6 +1093,7 @@ type peopleListRemovePayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Remove     map[string]any `json:"$remove"`
+	IP         string         `json:"$ip"`
 }
 
 // PeopleRemoveListProperty calls the User Remove from List Property API
@@ -400,31 +1103,46 @@ func (a *ApiClient) PeopleRemoveListProperty(ctx context.Context, distinctID str
 		{
 			Token:      a.token,
 			DistinctID: distinctID,
+			IP:         noGeoLookupIp,
 			Remove:     remove,
 		},
 	}
 	return a.doPeopleRequest(ctx, payload, peopleRemoveFromListUrl)
 }
 
+// ErrNoPropertiesToUnset is returned when an unset is requested without any properties
+var ErrNoPropertiesToUnset = errors.New("no properties to unset")
+
 type peopleDeletePropertyPayload struct {
 	Token      string   `json:"$token"`
 	DistinctID string   `json:"$distinct_id"`
 	Unset      []string `json:"$unset"`
+	IP         string   `json:"$ip"`
 }
 
 // PeopleDeleteProperty calls the User Delete Property API
 // https://developer.mixpanel.com/reference/profile-delete-property
 func (a *ApiClient) PeopleDeleteProperty(ctx context.Context, distinctID string, unset []string) error {
+	if len(unset) == 0 {
+		return ErrNoPropertiesToUnset
+	}
+
 	payload := []peopleDeletePropertyPayload{
 		{
 			Token:      a.token,
 			DistinctID: distinctID,
+			IP:         noGeoLookupIp,
 			Unset:      unset,
 		},
 	}
 	return a.doPeopleRequest(ctx, payload, peopleDeletePropertyUrl)
 }
 
+// PeopleDeletePropertyVariadic is PeopleDeleteProperty taking the properties as arguments
+func (a *ApiClient) PeopleDeletePropertyVariadic(ctx context.Context, distinctID string, props ...string) error {
+	return a.PeopleDeleteProperty(ctx, distinctID, props)
+}
+
 type peopleDeleteProfilePayload struct {
 	Token       string `json:"$token"`
 	DistinctID  string `json:"$distinct_id"`
@@ -456,6 +1174,7 @@ type groupSetPropertyPayload struct {
 // GroupUpdateProperty calls the Group Update Property API
 // https://developer.mixpanel.com/reference/group-set-property
 func (a *ApiClient) GroupSet(ctx context.Context, groupKey, groupID string, set map[string]any) error {
+	a.formatTimeProperties(set)
 	payload := []groupSetPropertyPayload{
 		{
 			Token:    a.token,
@@ -477,6 +1196,7 @@ type groupSetOncePropertyPayload struct {
 // GroupSetOnce calls the Group Set Property Once API
 // https://developer.mixpanel.com/reference/group-set-property-once
 func (a *ApiClient) GroupSetOnce(ctx context.Context, groupKey, groupID string, set map[string]any) error {
+	a.formatTimeProperties(set)
 	payload := []groupSetOncePropertyPayload{
 		{
 			Token:    a.token,
@@ -572,3 +1292,62 @@ func (a *ApiClient) GroupDelete(ctx context.Context, groupKey, groupID string) e
 
 	return a.doPeopleRequest(ctx, payload, groupsDeleteGroupUrl)
 }
+
+// GroupOps are the updates GroupBatchUpdate applies to one group, empty operations are skipped
+type GroupOps struct {
+	GroupID string
+	Set     map[string]any
+	SetOnce map[string]any
+	Union   map[string]any
+	Remove  map[string]any
+	Unset   []string
+}
+
+// GroupBatchUpdate applies the updates of many groups of groupKey with as few requests as possible
+// Updates are sent one operation at a time (set, set once, union, remove, then unset) in batches of MaxPeopleEvents
+// Stops at the first failed request
+func (a *ApiClient) GroupBatchUpdate(ctx context.Context, groupKey string, updates []GroupOps) error {
+	var set, setOnce, union, remove, unset []any
+	for _, u := range updates {
+		if len(u.Set) > 0 {
+			a.formatTimeProperties(u.Set)
+			set = append(set, groupSetPropertyPayload{Token: a.token, GroupKey: groupKey, GroupId: u.GroupID, Set: u.Set})
+		}
+		if len(u.SetOnce) > 0 {
+			a.formatTimeProperties(u.SetOnce)
+			setOnce = append(setOnce, groupSetOncePropertyPayload{Token: a.token, GroupKey: groupKey, GroupId: u.GroupID, SetOnce: u.SetOnce})
+		}
+		if len(u.Union) > 0 {
+			union = append(union, groupUnionListPropertyPayload{Token: a.token, GroupKey: groupKey, GroupId: u.GroupID, Union: u.Union})
+		}
+		if len(u.Remove) > 0 {
+			remove = append(remove, groupRemoveListPropertyPayload{Token: a.token, GroupKey: groupKey, GroupId: u.GroupID, Remove: u.Remove})
+		}
+		if len(u.Unset) > 0 {
+			unset = append(unset, groupDeletePropertyPayload{Token: a.token, GroupKey: groupKey, GroupId: u.GroupID, Unset: u.Unset})
+		}
+	}
+
+	for _, op := range []struct {
+		url      string
+		payloads []any
+	}{
+		{url: groupSetUrl, payloads: set},
+		{url: groupsSetOnceUrl, payloads: setOnce},
+		{url: groupsUnionListPropertyUrl, payloads: union},
+		{url: groupsRemoveFromListPropertyUrl, payloads: remove},
+		{url: groupsDeletePropertyUrl, payloads: unset},
+	} {
+		for start := 0; start < len(op.payloads); start += MaxPeopleEvents {
+			end := start + MaxPeopleEvents
+			if end > len(op.payloads) {
+				end = len(op.payloads)
+			}
+			if err := a.doPeopleRequest(ctx, op.payloads[start:end], op.url); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
@@ -20,6 +22,61 @@ import (
 )
 
 func TestEvent(t *testing.T) {
+	t.Run("add utm params", func(t *testing.T) {
+		u, err := url.Parse("https://example.com/landing?utm_source=newsletter&utm_medium=email&utm_campaign=launch&utm_term=go&utm_content=header&other=ignored")
+		require.NoError(t, err)
+
+		mp := NewApiClient("token")
+		e := mp.NewEvent("some-event", "some-id", nil)
+		e.AddUTMParams(u.Query())
+
+		require.Equal(t, "newsletter", e.Properties["utm_source"])
+		require.Equal(t, "email", e.Properties["utm_medium"])
+		require.Equal(t, "launch", e.Properties["utm_campaign"])
+		require.Equal(t, "go", e.Properties["utm_term"])
+		require.Equal(t, "header", e.Properties["utm_content"])
+		require.NotContains(t, e.Properties, "other")
+	})
+
+	t.Run("add referrer", func(t *testing.T) {
+		mp := NewApiClient("token")
+		e := mp.NewEvent("some-event", "some-id", nil)
+		e.AddReferrer("https://www.google.com/search?q=mixpanel")
+		require.Equal(t, "https://www.google.com/search?q=mixpanel", e.Properties["$referrer"])
+		require.Equal(t, "www.google.com", e.Properties["$referring_domain"])
+
+		e = mp.NewEvent("some-event", "some-id", nil)
+		e.AddReferrer("not a url")
+		require.Equal(t, "not a url", e.Properties["$referrer"])
+		require.NotContains(t, e.Properties, "$referring_domain")
+	})
+
+	t.Run("stamps the instance id", func(t *testing.T) {
+		mp := NewApiClient("token", WithInstanceID("pod-1"))
+		e := mp.NewEvent("some-event", "some-id", nil)
+		require.Equal(t, "pod-1", e.Properties[DefaultInstanceIDProperty])
+
+		mp = NewApiClient("token", WithInstanceID("pod-1"), WithInstanceIDKey("pod"))
+		e = mp.NewEvent("some-event", "some-id", nil)
+		require.Equal(t, "pod-1", e.Properties["pod"])
+		require.NotContains(t, e.Properties, DefaultInstanceIDProperty)
+	})
+
+	t.Run("derives an instance id from the hostname", func(t *testing.T) {
+		hostname, err := os.Hostname()
+		require.NoError(t, err)
+
+		mp := NewApiClient("token", WithInstanceID(""))
+		e := mp.NewAnonymousEvent("some-event", nil)
+		require.True(t, strings.HasPrefix(e.Properties[DefaultInstanceIDProperty].(string), hostname+"-"))
+	})
+
+	t.Run("no instance id by default", func(t *testing.T) {
+		mp := NewApiClient("token")
+		e := mp.NewEvent("some-event", "some-id", nil)
+		require.NotContains(t, e.Properties, DefaultInstanceIDProperty)
+	})
+
 	t.Run("does not panic with nil properties", func(t *testing.T) {
 		mp := NewApiClient("")
 		event := mp.NewEvent("some event", EmptyDistinctID, nil)
@@ -36,6 +93,37 @@ func TestEvent(t *testing.T) {
 		require.Equal(t, nowTime.UnixMilli(), event.Properties[propertyTime])
 	})
 
+	t.Run("event add time in seconds correctly", func(t *testing.T) {
+		mp := NewApiClient("")
+		event := mp.NewEvent("some event", EmptyDistinctID, nil)
+		event.AddTimeSeconds(1684951135)
+
+		require.Equal(t, int64(1684951135000), event.Properties[propertyTime])
+	})
+
+	t.Run("normalize time converts seconds to milliseconds", func(t *testing.T) {
+		tests := []struct {
+			name string
+			time any
+		}{
+			{name: "float seconds", time: float64(1684951135)},
+			{name: "int seconds", time: 1684951135},
+			{name: "json number seconds", time: json.Number("1684951135")},
+			{name: "milliseconds", time: int64(1684951135000)},
+			{name: "float milliseconds", time: float64(1684951135000)},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				mp := NewApiClient("")
+				event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{propertyTime: test.time})
+				event.NormalizeTime()
+
+				require.Equal(t, int64(1684951135000), event.Properties[propertyTime])
+			})
+		}
+	})
+
 	t.Run("insert id set correctly", func(t *testing.T) {
 		mp := NewApiClient("")
 		event := mp.NewEvent("some event", EmptyDistinctID, nil)
@@ -62,6 +150,54 @@ func TestEvent(t *testing.T) {
 
 		require.NotContains(t, event.Properties, propertyIP)
 	})
+
+	t.Run("anonymous event has no distinct id", func(t *testing.T) {
+		mp := NewApiClient("token")
+		event := mp.NewAnonymousEvent("some event", map[string]any{"$device_id": "some-device"})
+
+		require.NotContains(t, event.Properties, propertyDistinctID)
+		require.Equal(t, "token", event.Properties[propertyToken])
+		require.Equal(t, "some-device", event.Properties["$device_id"])
+	})
+
+	t.Run("set property on nil properties", func(t *testing.T) {
+		event := &Event{Name: "some event"}
+		require.NoError(t, event.SetProperty("some-key", "some-value"))
+		require.Equal(t, "some-value", event.Properties["some-key"])
+
+		event = &Event{Name: "some event"}
+		require.NoError(t, event.SetProperties(map[string]any{"some-key": "some-value"}))
+		require.Equal(t, "some-value", event.Properties["some-key"])
+	})
+
+	t.Run("set property does not overwrite reserved properties", func(t *testing.T) {
+		mp := NewApiClient("token")
+		event := mp.NewEvent("some event", EmptyDistinctID, nil)
+
+		require.ErrorIs(t, event.SetProperty(propertyToken, "other-token"), ErrReservedProperty)
+		require.ErrorIs(t, event.SetProperty(propertyMpLib, "other-lib"), ErrReservedProperty)
+		require.ErrorIs(t, event.SetProperties(map[string]any{
+			"some-key":         "some-value",
+			propertyLibVersion: "v0",
+		}), ErrReservedProperty)
+
+		require.Equal(t, "token", event.Properties[propertyToken])
+		require.Equal(t, goLib, event.Properties[propertyMpLib])
+		require.Equal(t, version, event.Properties[propertyLibVersion])
+		require.NotContains(t, event.Properties, "some-key")
+	})
+
+	t.Run("uses custom distinct id and token keys", func(t *testing.T) {
+		mp := NewApiClient("token", WithDistinctIDKey("user_id"), WithTokenKey("project_token"))
+		event := mp.NewEvent("some event", "some-id", nil)
+
+		require.Equal(t, "some-id", event.Properties["user_id"])
+		require.Equal(t, "token", event.Properties["project_token"])
+		require.NotContains(t, event.Properties, propertyDistinctID)
+		require.NotContains(t, event.Properties, propertyToken)
+		require.ErrorIs(t, event.SetProperty("project_token", "other-token"), ErrReservedProperty)
+		require.Equal(t, "token", event.Properties["project_token"])
+	})
 }
 
 func TestNewEventFromJson(t *testing.T) {
@@ -121,6 +257,37 @@ func TestNewEventFromJson(t *testing.T) {
 }
 
 func TestTrack(t *testing.T) {
+	t.Run("default query params", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		query := url.Values{}
+		query.Add("verbose", "1")
+		query.Add("route", "eu-gateway")
+		httpmock.RegisterResponderWithQuery(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), query, httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		// the sdk's own verbose param is not replaced
+		mp := NewApiClient("token", WithDefaultQueryParams(url.Values{
+			"route":   {"eu-gateway"},
+			"verbose": {"0"},
+		}))
+		require.NoError(t, mp.Track(context.Background(), []*Event{mp.NewEvent("some-event", "some-id", nil)}))
+	})
+
+	t.Run("default query params on people requests", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		// httpmock drops the #profile-set fragment when matching a url with a query
+		httpmock.RegisterResponder(http.MethodPost, "=~^"+usEndpoint+"/engage", func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "profile-set", req.URL.Fragment)
+			require.Equal(t, "eu-gateway", req.URL.Query().Get("route"))
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		mp := NewApiClient("token", WithDefaultQueryParams(url.Values{"route": {"eu-gateway"}}))
+		require.NoError(t, mp.PeopleSet(context.Background(), []*PeopleProperties{NewPeopleProperties("some-id", nil)}))
+	})
+
 	setupHttpEndpointTest := func(t *testing.T, client *ApiClient, testPayload func([]*Event), httpResponse *http.Response) {
 		httpmock.Activate()
 		t.Cleanup(httpmock.DeactivateAndReset)
@@ -130,8 +297,18 @@ func TestTrack(t *testing.T) {
 			require.Equal(t, req.Header.Get("accept"), "text/plain")
 			require.Equal(t, "1", req.URL.Query().Get("verbose"))
 
+			reader := req.Body
+			if client.trackCompression == Gzip {
+				require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+				var err error
+				reader, err = gzip.NewReader(req.Body)
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, "", req.Header.Get("Content-Encoding"))
+			}
+
 			var r []*Event
-			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			require.NoError(t, json.NewDecoder(reader).Decode(&r))
 			testPayload(r)
 
 			return httpResponse, nil
@@ -184,6 +361,19 @@ func TestTrack(t *testing.T) {
 		require.Error(t, usMixpanel.Track(ctx, events))
 	})
 
+	t.Run("track one event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		event := mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{})
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 1)
+			require.Equal(t, event, r[0])
+		}, trackSuccess())
+
+		require.NoError(t, mp.TrackOne(ctx, event))
+	})
+
 	t.Run("track multiple events successfully", func(t *testing.T) {
 		ctx := context.Background()
 		mp := NewApiClient("token")
@@ -214,6 +404,15 @@ func TestTrack(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("return error on a nil event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{mp.NewEvent("some event", EmptyDistinctID, map[string]any{}), nil}
+
+		err := mp.Track(ctx, events)
+		require.EqualError(t, err, "event 1 is nil")
+	})
+
 	t.Run("track call failed and return error", func(t *testing.T) {
 		ctx := context.Background()
 		mp := NewApiClient("token")
@@ -236,318 +435,1371 @@ func TestTrack(t *testing.T) {
 
 		require.Error(t, mp.Track(ctx, events))
 	})
-}
 
-func TestImport(t *testing.T) {
-	setupHttpEndpointTest := func(t *testing.T, client *ApiClient, queryValues url.Values, testPayload func([]*Event), httpResponse *http.Response) {
-		httpmock.Activate()
-		t.Cleanup(httpmock.DeactivateAndReset)
-
-		httpmock.RegisterResponderWithQuery(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, importURL), queryValues, func(req *http.Request) (*http.Response, error) {
-			auth := req.Header.Get("authorization")
-			if client.serviceAccount != nil {
-				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.serviceAccount.Username+":"+client.serviceAccount.Secret)))
-			} else if client.apiSecret != "" {
-				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.apiSecret+":")))
-			} else {
-				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.token+":")))
-			}
+	t.Run("event order is preserved", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
 
-			compress := req.Header.Get("content-encoding")
-			reader := req.Body
-			if compress == "gzip" {
-				require.Equal(t, req.Header.Get("Content-Encoding"), "gzip")
-				var err error
-				reader, err = gzip.NewReader(req.Body)
-				require.NoError(t, err)
-			} else {
-				require.Equal(t, req.Header.Get("Content-Encoding"), "")
+		var events []*Event
+		for i := 0; i < 100; i++ {
+			events = append(events, mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{"sequence": i}))
+		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 100)
+			for i, e := range r {
+				require.Equal(t, float64(i), e.Properties["sequence"])
 			}
+		}, trackSuccess())
 
-			var r []*Event
-			require.NoError(t, json.NewDecoder(reader).Decode(&r))
-			testPayload(r)
+		require.NoError(t, mp.Track(ctx, events))
+	})
 
-			return httpResponse, nil
-		})
-	}
+	t.Run("can track gzip if requested", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithTrackCompression(Gzip))
 
-	getValues := func(projectID int, strict bool) url.Values {
-		query := url.Values{}
-		query.Add("verbose", "1")
-		if strict {
-			query.Add("strict", "1")
-		} else {
-			query.Add("strict", "0")
+		events := []*Event{
+			mp.NewEvent("sample_event_1", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("sample_event_2", EmptyDistinctID, map[string]any{}),
 		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 2)
+			require.ElementsMatch(t, events, r)
+		}, trackSuccess())
 
-		query.Add("project_id", strconv.Itoa(projectID))
+		require.NoError(t, mp.Track(ctx, events))
+	})
 
-		return query
-	}
+	t.Run("enrichers run in order before sending", func(t *testing.T) {
+		type requestIDKey struct{}
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "request-1")
+		mp := NewApiClient("token",
+			WithEventEnricher(func(ctx context.Context, e *Event) {
+				e.Properties["request_id"] = ctx.Value(requestIDKey{})
+				e.Properties["order"] = "first"
+			}),
+			WithEventEnricher(func(ctx context.Context, e *Event) {
+				e.Properties["order"] = e.Properties["order"].(string) + ",second"
+			}),
+		)
 
-	t.Run("import successfully", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{
+			mp.NewEvent("sample_event_1", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("sample_event_2", EmptyDistinctID, map[string]any{}),
+		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 2)
+			for _, e := range r {
+				require.Equal(t, "request-1", e.Properties["request_id"])
+				require.Equal(t, "first,second", e.Properties["order"])
+			}
+		}, trackSuccess())
 
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
-		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
-		})
+		require.NoError(t, mp.Track(ctx, events))
+	})
 
-		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
-		require.NoError(t, err)
+	t.Run("filtered events are not sent", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventFilter(func(e *Event) bool {
+			return e.Name != "debug_event"
+		}))
 
-		require.Equal(t, 1, success.NumRecordsImported)
-	})
+		events := []*Event{
+			mp.NewEvent("sample_event_1", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("debug_event", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("sample_event_2", EmptyDistinctID, map[string]any{}),
+		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 2)
+			require.Equal(t, "sample_event_1", r[0].Name)
+			require.Equal(t, "sample_event_2", r[1].Name)
+		}, trackSuccess())
 
-	t.Run("api-secret", func(t *testing.T) {
-		query := url.Values{}
-		query.Add("verbose", "1")
-		query.Add("strict", "1")
+		require.NoError(t, mp.Track(ctx, events))
+		require.Equal(t, uint64(1), mp.DroppedEvents())
+	})
 
+	t.Run("no request if all events are filtered", func(t *testing.T) {
 		ctx := context.Background()
-		mp := NewApiClient("token", ApiSecret("some-secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		mp := NewApiClient("token", WithEventFilter(func(e *Event) bool {
+			return false
+		}))
 
-		setupHttpEndpointTest(t, mp, query, func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
-		})
+		setupHttpEndpointTest(t, mp, func(r []*Event) {}, trackSuccess())
 
-		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		result, err := mp.TrackWithResult(ctx, []*Event{mp.NewEvent("debug_event", EmptyDistinctID, map[string]any{})})
 		require.NoError(t, err)
-
-		require.Equal(t, 1, success.NumRecordsImported)
+		require.Equal(t, 0, result.EventsSent)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
 	})
 
-	t.Run("api-token", func(t *testing.T) {
-		query := url.Values{}
-		query.Add("verbose", "1")
-		query.Add("strict", "1")
-
+	t.Run("track with result on success", func(t *testing.T) {
 		ctx := context.Background()
 		mp := NewApiClient("token")
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
 
-		setupHttpEndpointTest(t, mp, query, func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
-		})
+		events := []*Event{
+			mp.NewEvent("sample_event_1", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("sample_event_2", EmptyDistinctID, map[string]any{}),
+		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {
+			require.Len(t, r, 2)
+		}, trackSuccess())
 
-		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		result, err := mp.TrackWithResult(ctx, events)
 		require.NoError(t, err)
-
-		require.Equal(t, 1, success.NumRecordsImported)
+		require.Equal(t, &TrackResult{Status: 1, EventsSent: 2}, result)
 	})
 
-	t.Run("can import gzip if requested", func(t *testing.T) {
+	t.Run("track with result on failure", func(t *testing.T) {
 		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		mp := NewApiClient("token")
 
-		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
+		events := []*Event{
+			mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{}),
+		}
+		setupHttpEndpointTest(t, mp, func(r []*Event) {}, &http.Response{
 			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"error": "some error occurred",
+				"status": 0
+			}
+			`)),
 		})
 
-		success, err := mp.Import(ctx, events, ImportOptions{
-			Strict:      true,
-			Compression: Gzip,
-		})
+		result, err := mp.TrackWithResult(ctx, events)
+		verboseError := &VerboseError{}
+		require.ErrorAs(t, err, verboseError)
+		require.Equal(t, &TrackResult{Status: 0, ApiError: "some error occurred", EventsSent: 1}, result)
+	})
+}
+
+func TestEstimateBatchSize(t *testing.T) {
+	mp := NewApiClient("token")
+	var events []*Event
+	for i := 0; i < 100; i++ {
+		events = append(events, mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{"sequence": i}))
+	}
+
+	jsonData, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	t.Run("no compression", func(t *testing.T) {
+		uncompressed, compressed, err := EstimateBatchSize(events, None)
 		require.NoError(t, err)
+		require.Equal(t, len(jsonData), uncompressed)
+		require.Equal(t, len(jsonData), compressed)
+	})
 
-		require.Equal(t, 1, success.NumRecordsImported)
+	t.Run("gzip", func(t *testing.T) {
+		gzipData, err := gzipBody(jsonData)
+		require.NoError(t, err)
+
+		uncompressed, compressed, err := EstimateBatchSize(events, Gzip)
+		require.NoError(t, err)
+		require.Equal(t, len(jsonData), uncompressed)
+		require.Equal(t, len(gzipData), compressed)
+		require.Less(t, compressed, uncompressed)
 	})
+}
 
-	t.Run("can import non gzip data if requested", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+func TestTrackPixel(t *testing.T) {
+	decodeData := func(t *testing.T, query url.Values) *Event {
+		data, err := base64.StdEncoding.DecodeString(query.Get("data"))
+		require.NoError(t, err)
 
-		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
+		var e *Event
+		require.NoError(t, json.Unmarshal(data, &e))
+		return e
+	}
+
+	t.Run("url round trips the event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		event := mp.NewEvent("sample_event", "some-id", map[string]any{
+			"some-key": "some-value",
+		})
+
+		pixelUrl, err := mp.TrackPixel(ctx, event)
+		require.NoError(t, err)
+
+		u, err := url.Parse(pixelUrl)
+		require.NoError(t, err)
+		require.Equal(t, usEndpoint+trackURL, fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path))
+		require.Equal(t, "1", u.Query().Get("img"))
+
+		decoded := decodeData(t, u.Query())
+		require.Equal(t, event.Name, decoded.Name)
+		require.Equal(t, "some-id", decoded.Properties[propertyDistinctID])
+		require.Equal(t, "some-value", decoded.Properties["some-key"])
+	})
+
+	t.Run("can send the pixel", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		event := mp.NewEvent("sample_event", "some-id", nil)
+
+		httpmock.RegisterResponder(http.MethodGet, usEndpoint+trackURL, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "1", req.URL.Query().Get("verbose"))
+			require.Equal(t, event.Name, decodeData(t, req.URL.Query()).Name)
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"error": "", "status": 1}`)),
+			}, nil
+		})
+
+		require.NoError(t, mp.SendTrackPixel(ctx, event))
+	})
+
+	t.Run("send runs the event hooks", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		ctx := context.Background()
+		httpmock.RegisterResponder(http.MethodGet, usEndpoint+trackURL, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "opt-in", req.Header.Get(ConsentHeader))
+			decoded := decodeData(t, req.URL.Query())
+			require.Equal(t, "enriched", decoded.Properties["enriched"])
+			require.Equal(t, "opt-in", decoded.Properties[ConsentProperty])
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		var beforeSend int
+		mp := NewApiClient("token",
+			WithEventFilter(func(e *Event) bool { return e.Name != "dropped" }),
+			WithEventEnricher(func(ctx context.Context, e *Event) { e.Properties["enriched"] = "enriched" }),
+			WithConsent("opt-in", ConsentAsPropertyAndHeader),
+			WithBeforeSend(func(ctx context.Context, endpoint string, events []*Event) error {
+				beforeSend++
+				return nil
+			}),
+		)
+
+		require.NoError(t, mp.SendTrackPixel(ctx, mp.NewEvent("sample_event", "some-id", nil)))
+		require.NoError(t, mp.SendTrackPixel(ctx, mp.NewEvent("dropped", "some-id", nil)))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+		require.Equal(t, 1, beforeSend)
+	})
+}
+
+func TestTrackIsolated(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("re-sends the batch without the rejected events", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		query := url.Values{}
+		query.Add("verbose", "1")
+		query.Add("strict", "1")
+
+		var sent [][]string
+		httpmock.RegisterResponderWithQuery(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), query, func(req *http.Request) (*http.Response, error) {
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+
+			var names []string
+			for _, e := range events {
+				names = append(names, e.Name)
+			}
+			sent = append(sent, names)
+
+			if len(sent) == 1 {
+				return httpmock.NewStringResponse(http.StatusBadRequest, `{"code": 400, "error": "some data points in the request failed validation", "failed_records": [{"index": 1, "insert_id": "", "field": "properties.time", "message": "'properties.time' is invalid"}], "num_records_imported": 0, "status": "Bad Request"}`), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		mp := NewApiClient("token")
+		events := []*Event{
+			mp.NewEvent("good-1", "some-id", map[string]any{}),
+			mp.NewEvent("bad", "some-id", map[string]any{}),
+			mp.NewEvent("good-2", "some-id", map[string]any{}),
+		}
+
+		dropped, err := mp.TrackIsolated(ctx, events)
+		require.NoError(t, err)
+		require.Equal(t, []*Event{events[1]}, dropped)
+		require.Equal(t, [][]string{{"good-1", "bad", "good-2"}, {"good-1", "good-2"}}, sent)
+	})
+
+	t.Run("does not re-send events mixpanel already imported", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusBadRequest, `{"code": 400, "error": "some data points in the request failed validation", "failed_records": [{"index": 1, "insert_id": "", "field": "properties.time", "message": "'properties.time' is invalid"}], "num_records_imported": 2, "status": "Bad Request"}`))
+
+		mp := NewApiClient("token")
+		events := []*Event{
+			mp.NewEvent("good-1", "some-id", map[string]any{}),
+			mp.NewEvent("bad", "some-id", map[string]any{}),
+			mp.NewEvent("good-2", "some-id", map[string]any{}),
+		}
+
+		dropped, err := mp.TrackIsolated(ctx, events)
+		require.NoError(t, err)
+		require.Equal(t, []*Event{events[1]}, dropped)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("nothing dropped on success", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token")
+		dropped, err := mp.TrackIsolated(ctx, []*Event{mp.NewEvent("good", "some-id", map[string]any{})})
+		require.NoError(t, err)
+		require.Empty(t, dropped)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "some error", "status": 0}`))
+
+		mp := NewApiClient("token")
+		_, err := mp.TrackIsolated(ctx, []*Event{mp.NewEvent("good", "some-id", map[string]any{})})
+		var verboseError VerboseError
+		require.ErrorAs(t, err, &verboseError)
+		require.Equal(t, "some error", verboseError.ApiError)
+	})
+}
+
+func TestImport(t *testing.T) {
+	setupHttpEndpointTest := func(t *testing.T, client *ApiClient, queryValues url.Values, testPayload func([]*Event), httpResponse *http.Response) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponderWithQuery(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, importURL), queryValues, func(req *http.Request) (*http.Response, error) {
+			auth := req.Header.Get("authorization")
+			if client.serviceAccount != nil {
+				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.serviceAccount.Username+":"+client.serviceAccount.Secret)))
+			} else if client.apiSecret != "" {
+				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.apiSecret+":")))
+			} else {
+				require.Equal(t, auth, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.token+":")))
+			}
+
+			compress := req.Header.Get("content-encoding")
+			reader := req.Body
+			if compress == "gzip" {
+				require.Equal(t, req.Header.Get("Content-Encoding"), "gzip")
+				var err error
+				reader, err = gzip.NewReader(req.Body)
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, req.Header.Get("Content-Encoding"), "")
+			}
+
+			var r []*Event
+			require.NoError(t, json.NewDecoder(reader).Decode(&r))
+			testPayload(r)
+
+			return httpResponse, nil
+		})
+	}
+
+	getValues := func(projectID int, strict bool) url.Values {
+		query := url.Values{}
+		query.Add("verbose", "1")
+		if strict {
+			query.Add("strict", "1")
+		} else {
+			query.Add("strict", "0")
+		}
+
+		query.Add("project_id", strconv.Itoa(projectID))
+
+		return query
+	}
+
+	t.Run("import successfully", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+		require.True(t, success.IsFullSuccess())
+		require.Equal(t, "1", success.StatusText())
+	})
+
+	t.Run("import one event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		event := mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Equal(t, []*Event{event}, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.ImportOne(ctx, event, ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("client default options", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithDefaultImportOptions(ImportOptionsRecommend))
+
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		setupHttpEndpointTest(t, mp, getValues(117, true), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		_, err := mp.Import(ctx, events, ImportOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("call options override the client default", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithDefaultImportOptions(ImportOptionsRecommend))
+
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		setupHttpEndpointTest(t, mp, getValues(117, false), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		_, err := mp.Import(ctx, events, ImportOptions{Compression: None, InsertIDFromProperty: "id"})
+		require.NoError(t, err)
+	})
+
+	t.Run("200 response with a failure status", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 0,"status": 0}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.False(t, success.IsFullSuccess())
+	})
+
+	t.Run("api-secret", func(t *testing.T) {
+		query := url.Values{}
+		query.Add("verbose", "1")
+		query.Add("strict", "1")
+
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("some-secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, query, func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("api-token", func(t *testing.T) {
+		query := url.Values{}
+		query.Add("verbose", "1")
+		query.Add("strict", "1")
+
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, query, func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("can import gzip if requested", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
 			StatusCode: http.StatusOK,
 			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
 		})
 
 		success, err := mp.Import(ctx, events, ImportOptions{
 			Strict:      true,
-			Compression: None,
+			Compression: Gzip,
 		})
 		require.NoError(t, err)
-
-		require.Equal(t, 1, success.NumRecordsImported)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("can import non gzip data if requested", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptions{
+			Strict:      true,
+			Compression: None,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("event order is preserved", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		var events []*Event
+		for i := 0; i < 100; i++ {
+			events = append(events, mp.NewEvent("import-event", EmptyDistinctID, map[string]any{"sequence": i}))
+		}
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Len(t, r, 100)
+			for i, e := range r {
+				require.Equal(t, float64(i), e.Properties["sequence"])
+			}
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 100,"status": 1}`)),
+		})
+
+		_, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+	})
+
+	t.Run("can set insert id from a property", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{
+			mp.NewEvent("import-event", EmptyDistinctID, map[string]any{"order_id": "order-1"}),
+			mp.NewEvent("import-event", EmptyDistinctID, map[string]any{"order_id": float64(1684951135)}),
+			mp.NewEvent("import-event", EmptyDistinctID, map[string]any{"order_id": "order-3", propertyInsertID: "existing"}),
+			mp.NewEvent("import-event", EmptyDistinctID, map[string]any{}),
+		}
+
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Len(t, r, 4)
+			require.Equal(t, "order-1", r[0].Properties[propertyInsertID])
+			require.Equal(t, "1684951135", r[1].Properties[propertyInsertID])
+			require.Equal(t, "existing", r[2].Properties[propertyInsertID])
+			require.NotContains(t, r[3].Properties, propertyInsertID)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 4,"status": 1}`)),
+		})
+
+		_, err := mp.Import(ctx, events, ImportOptions{
+			Strict:               true,
+			Compression:          Gzip,
+			InsertIDFromProperty: "order_id",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("exported second based time is imported in milliseconds", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		var exported map[string]any
+		require.NoError(t, json.Unmarshal([]byte(`{"event":"test","properties":{"time":1684951135,"$insert_id":"some-insert-id"}}`), &exported))
+		event, err := mp.NewEventFromJson(exported)
+		require.NoError(t, err)
+
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {
+			require.Len(t, r, 1)
+			require.Equal(t, float64(1684951135000), r[0].Properties[propertyTime])
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		_, err = mp.Import(ctx, []*Event{event}, ImportOptionsRecommend)
+		require.NoError(t, err)
+	})
+
+	t.Run("can enable strict mode if requested", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, getValues(117, true), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptions{
+			Strict:      true,
+			Compression: Gzip,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("can disable strict mode if requested", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, getValues(117, false), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptions{
+			Strict:      false,
+			Compression: Gzip,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, success.NumRecordsImported)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"code": 400,
+				"status": "Bad Request",
+				"num_records_imported": 1,
+				"error": "some data points in the request failed validation",
+				"failed_records": [
+					{
+						"index": 1,
+						"field": "event",
+						"insert_id": "some-insert-id",
+						"message": "'event' must not be missing or blank"
+					}
+				]
+			}
+			`)),
+		})
+
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
+		validationError := &ImportFailedValidationError{}
+		require.ErrorAs(t, err, validationError)
+		require.Equal(t, 1, validationError.NumRecordsImported)
+		require.Equal(t, 1, len(validationError.FailedImportRecords))
+		require.Equal(t, "some-insert-id", validationError.FailedImportRecords[0].InsertID)
+		require.Equal(t, "event", validationError.FailedImportRecords[0].Field)
+		require.Nil(t, validationError.FailedImportRecords[0].Event)
+	})
+
+	t.Run("failed records reference the rejected event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithEventFilter(func(e *Event) bool {
+			return e.Name != "dropped"
+		}))
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"code": 400,
+				"status": "Bad Request",
+				"num_records_imported": 1,
+				"error": "some data points in the request failed validation",
+				"failed_records": [
+					{
+						"index": 1,
+						"field": "event",
+						"insert_id": "some-insert-id",
+						"message": "'event' must not be missing or blank"
+					}
+				]
+			}
+			`)),
+		})
+
+		events := []*Event{
+			mp.NewEvent("good", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("dropped", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("bad", EmptyDistinctID, map[string]any{}),
+		}
+		_, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		validationError := &ImportFailedValidationError{}
+		require.ErrorAs(t, err, validationError)
+		require.Len(t, validationError.FailedImportRecords, 1)
+		require.Same(t, events[2], validationError.FailedImportRecords[0].Event)
+	})
+
+	t.Run("rate limit exceeded", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"code": 429,
+				"error":"rate limit exceeded",
+				"status": 0
+			}
+			`)),
+		})
+
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
+		rateLimitError := &ImportRateLimitError{}
+		require.ErrorAs(t, err, rateLimitError)
+	})
+
+	t.Run("test know status code errors", func(t *testing.T) {
+		tests := []struct {
+			httpStatusCode int
+		}{
+			{
+				httpStatusCode: http.StatusUnauthorized,
+			},
+			{
+				httpStatusCode: http.StatusRequestEntityTooLarge,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("http status code %d", test.httpStatusCode), func(t *testing.T) {
+				ctx := context.Background()
+				mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+				setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+					StatusCode: test.httpStatusCode,
+					Body: io.NopCloser(strings.NewReader(fmt.Sprintf(`
+					{
+						"code": %d,
+						"error":"Unauthorized",
+						"status": 0
+					  }
+					`, test.httpStatusCode))),
+				})
+
+				_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
+				genericError := &ImportGenericError{}
+				require.ErrorAs(t, err, genericError)
+				require.Equal(t, test.httpStatusCode, genericError.Code)
+			})
+		}
+	})
+
+	t.Run("unknown status code", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+
+		setupHttpEndpointTest(t, mp, getValues(117, false), func(r []*Event) {
+			require.Equal(t, events, r)
+		}, &http.Response{
+			StatusCode: http.StatusTeapot,
+			Body:       io.NopCloser(strings.NewReader("i'm a teapot")),
+		})
+
+		_, err := mp.Import(ctx, events, ImportOptions{
+			Strict:      false,
+			Compression: Gzip,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("don't send more events then allowed", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		var events []*Event
+		for i := 0; i < MaxImportEvents+1; i++ {
+			events = append(events, mp.NewEvent("some event", EmptyDistinctID, map[string]any{}))
+		}
+
+		_, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.Error(t, err)
+	})
+
+	t.Run("return error on a nil event", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{nil, mp.NewEvent("some event", EmptyDistinctID, map[string]any{})}
+
+		_, err := mp.Import(ctx, events, ImportOptions{InsertIDFromProperty: "id"})
+		require.EqualError(t, err, "event 0 is nil")
+	})
+}
+
+func TestDeadLetter(t *testing.T) {
+	type deadLetter struct {
+		events []*Event
+		cause  error
+	}
+	newClient := func(letters *[]deadLetter, options ...Options) *ApiClient {
+		options = append(options, WithDeadLetter(func(ctx context.Context, events []*Event, cause error) {
+			*letters = append(*letters, deadLetter{events: events, cause: cause})
+		}))
+		return NewApiClient("token", options...)
+	}
+
+	t.Run("track failure", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "some error", "status": 0}`))
+
+		var letters []deadLetter
+		mp := newClient(&letters)
+		events := []*Event{mp.NewEvent("some-event", "some-id", map[string]any{})}
+
+		err := mp.Track(context.Background(), events)
+		require.Error(t, err)
+		require.Len(t, letters, 1)
+		require.Equal(t, events, letters[0].events)
+		require.Equal(t, err, letters[0].cause)
+	})
+
+	t.Run("import failure", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, importURL), httpmock.NewStringResponder(http.StatusTooManyRequests, `{"code": 429, "error": "rate limited", "status": 0}`))
+
+		var letters []deadLetter
+		mp := newClient(&letters, ServiceAccount(117, "user-name", "secret"))
+		events := []*Event{
+			mp.NewEvent("some-event", "some-id", map[string]any{}),
+			mp.NewEvent("some-other-event", "some-id", map[string]any{}),
+		}
+
+		_, err := mp.Import(context.Background(), events, ImportOptionsRecommend)
+		require.Error(t, err)
+		require.Len(t, letters, 1)
+		require.Equal(t, events, letters[0].events)
+		require.Equal(t, err, letters[0].cause)
+	})
+
+	t.Run("not called on success", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		var letters []deadLetter
+		mp := newClient(&letters)
+
+		err := mp.Track(context.Background(), []*Event{mp.NewEvent("some-event", "some-id", map[string]any{})})
+		require.NoError(t, err)
+		require.Empty(t, letters)
+	})
+}
+
+func TestBeforeSend(t *testing.T) {
+	ctx := context.Background()
+	errKillSwitch := errors.New("kill switch")
+
+	t.Run("track is aborted", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		var endpoint string
+		var letters int
+		mp := NewApiClient("token",
+			WithBeforeSend(func(ctx context.Context, e string, events []*Event) error {
+				endpoint = e
+				require.Len(t, events, 1)
+				return errKillSwitch
+			}),
+			WithDeadLetter(func(ctx context.Context, events []*Event, cause error) {
+				letters++
+			}),
+		)
+
+		err := mp.Track(ctx, []*Event{mp.NewEvent("some-event", "some-id", nil)})
+		require.ErrorIs(t, err, errKillSwitch)
+		require.Equal(t, usEndpoint+trackURL, endpoint)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+		require.Equal(t, 0, letters)
+	})
+
+	t.Run("import is aborted", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, httpmock.NewStringResponder(http.StatusOK, `{"code": 200, "num_records_imported": 1, "status": 1}`))
+
+		var endpoint string
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithBeforeSend(func(ctx context.Context, e string, events []*Event) error {
+			endpoint = e
+			return errKillSwitch
+		}))
+
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("some-event", "some-id", nil)}, ImportOptionsRecommend)
+		require.ErrorIs(t, err, errKillSwitch)
+		require.Equal(t, usEndpoint+importURL, endpoint)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("nil error sends the request", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token", WithBeforeSend(func(ctx context.Context, endpoint string, events []*Event) error {
+			return nil
+		}))
+
+		require.NoError(t, mp.Track(ctx, []*Event{mp.NewEvent("some-event", "some-id", nil)}))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestConsent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("track sends the consent property and header", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "opt-in", req.Header.Get(ConsentHeader))
+
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			require.Len(t, events, 2)
+			require.Equal(t, "opt-in", events[0].Properties[ConsentProperty])
+			require.Equal(t, "do-not-sell", events[1].Properties[ConsentProperty])
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		mp := NewApiClient("token", WithConsent("opt-in", ConsentAsPropertyAndHeader))
+		require.NoError(t, mp.Track(ctx, []*Event{
+			mp.NewEvent("some-event", "some-id", nil),
+			mp.NewEvent("some-event", "some-id", map[string]any{ConsentProperty: "do-not-sell"}),
+		}))
+	})
+
+	t.Run("import sends only the consent header", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "opt-in", req.Header.Get(ConsentHeader))
+
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			require.Len(t, events, 1)
+			require.NotContains(t, events[0].Properties, ConsentProperty)
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"code": 200, "num_records_imported": 1, "status": "OK"}`), nil
+		})
+
+		mp := NewApiClient("token", ApiSecret("api-secret"), WithConsent("opt-in", ConsentAsHeader))
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("some-event", "some-id", nil)}, ImportOptions{Compression: None})
+		require.NoError(t, err)
+	})
+
+	t.Run("no consent by default", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+trackURL, func(req *http.Request) (*http.Response, error) {
+			require.Empty(t, req.Header.Get(ConsentHeader))
+
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			require.NotContains(t, events[0].Properties, ConsentProperty)
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		mp := NewApiClient("token")
+		require.NoError(t, mp.Track(ctx, []*Event{mp.NewEvent("some-event", "some-id", nil)}))
+	})
+}
+
+func TestImportSuccess(t *testing.T) {
+	for _, tc := range []struct {
+		body        string
+		fullSuccess bool
+	}{
+		{body: `{"code": 200, "num_records_imported": 1, "status": "OK"}`, fullSuccess: true},
+		{body: `{"code": 200, "num_records_imported": 1, "status": 1}`, fullSuccess: true},
+		{body: `{"code": 200, "num_records_imported": 1, "status": 0}`, fullSuccess: false},
+		{body: `{"code": 200, "num_records_imported": 1, "status": "Bad Request"}`, fullSuccess: false},
+		{body: `{"code": 400, "num_records_imported": 1, "status": "OK"}`, fullSuccess: false},
+		{body: `{}`, fullSuccess: true},
+	} {
+		var s ImportSuccess
+		require.NoError(t, json.Unmarshal([]byte(tc.body), &s))
+		require.Equal(t, tc.fullSuccess, s.IsFullSuccess(), tc.body)
+	}
+}
+
+func TestImportChannel(t *testing.T) {
+	setupImportEndpoint := func(t *testing.T, client *ApiClient, status int, batches *[]int) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+			reader, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+
+			var r []*Event
+			require.NoError(t, json.NewDecoder(reader).Decode(&r))
+			*batches = append(*batches, len(r))
+
+			if status != http.StatusOK {
+				return httpmock.NewStringResponse(status, `{"code": 413, "error": "too large", "status": 0}`), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
+		})
+	}
+
+	produce := func(mp *ApiClient, count int) <-chan *Event {
+		ch := make(chan *Event)
+		go func() {
+			defer close(ch)
+			for i := 0; i < count; i++ {
+				ch <- mp.NewEvent("import-event", "some-id", map[string]any{})
+			}
+		}()
+		return ch
+	}
+
+	t.Run("configured batch size", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		var batches []int
+		setupImportEndpoint(t, mp, http.StatusOK, &batches)
+
+		success, err := mp.ImportChannel(context.Background(), produce(mp, 1200), ImportOptions{BatchSize: 500})
+		require.NoError(t, err)
+		require.Equal(t, []int{500, 500, 200}, batches)
+		require.Equal(t, 1200, success.NumRecordsImported)
+	})
+
+	t.Run("order is preserved across batches", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var sequence []float64
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			reader, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+
+			var r []*Event
+			require.NoError(t, json.NewDecoder(reader).Decode(&r))
+			for _, e := range r {
+				sequence = append(sequence, e.Properties["sequence"].(float64))
+			}
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		count := MaxImportEvents + 5
+		ch := make(chan *Event)
+		go func() {
+			defer close(ch)
+			for i := 0; i < count; i++ {
+				ch <- mp.NewEvent("import-event", "some-id", map[string]any{"sequence": i})
+			}
+		}()
+
+		_, err := mp.ImportChannel(context.Background(), ch, ImportOptions{})
+		require.NoError(t, err)
+		require.Len(t, sequence, count)
+		for i, seq := range sequence {
+			require.Equal(t, float64(i), seq)
+		}
+	})
+
+	t.Run("sends full batches and a final partial batch", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		var batches []int
+		setupImportEndpoint(t, mp, http.StatusOK, &batches)
+
+		success, err := mp.ImportChannel(context.Background(), produce(mp, MaxImportEvents*2+5), ImportOptions{Strict: true})
+		require.NoError(t, err)
+		require.Equal(t, []int{MaxImportEvents, MaxImportEvents, 5}, batches)
+		require.Equal(t, MaxImportEvents*2+5, success.NumRecordsImported)
+	})
+
+	t.Run("closed empty channel sends nothing", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		var batches []int
+		setupImportEndpoint(t, mp, http.StatusOK, &batches)
+
+		ch := make(chan *Event)
+		close(ch)
+		success, err := mp.ImportChannel(context.Background(), ch, ImportOptions{})
+		require.NoError(t, err)
+		require.Empty(t, batches)
+		require.Equal(t, 0, success.NumRecordsImported)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		var batches []int
+		setupImportEndpoint(t, mp, http.StatusRequestEntityTooLarge, &batches)
+
+		ch := make(chan *Event, 1)
+		ch <- mp.NewEvent("import-event", "some-id", map[string]any{})
+		close(ch)
+
+		_, err := mp.ImportChannel(context.Background(), ch, ImportOptions{})
+		var g ImportGenericError
+		require.ErrorAs(t, err, &g)
+		require.Equal(t, []int{1}, batches)
+	})
+
+	t.Run("stops on context cancellation", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := mp.ImportChannel(ctx, make(chan *Event), ImportOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestImportChunked(t *testing.T) {
+	ctx := context.Background()
+
+	newEvents := func(mp *ApiClient, count int) []*Event {
+		events := make([]*Event, count)
+		for i := range events {
+			events[i] = mp.NewEvent("import-event", "some-id", map[string]any{})
+		}
+		return events
+	}
+
+	t.Run("reports each chunk", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		calls := 0
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			calls++
+
+			imported := len(r)
+			if calls == 2 {
+				// the second chunk was partly deduplicated
+				imported -= 3
+			}
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, imported)), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		result, err := mp.ImportChunked(ctx, newEvents(mp, MaxImportEvents+10), ImportOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []ImportChunkResult{
+			{Index: 0, EventsSent: MaxImportEvents, NumRecordsImported: MaxImportEvents},
+			{Index: 1, EventsSent: 10, NumRecordsImported: 7},
+		}, result.Chunks)
+		require.Equal(t, MaxImportEvents+7, result.NumRecordsImported)
 	})
 
-	t.Run("can enable strict mode if requested", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+	t.Run("configured batch size", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
 
-		setupHttpEndpointTest(t, mp, getValues(117, true), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
 		})
 
-		success, err := mp.Import(ctx, events, ImportOptions{
-			Strict:      true,
-			Compression: Gzip,
-		})
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		result, err := mp.ImportChunked(ctx, newEvents(mp, 1200), ImportOptions{BatchSize: 500})
 		require.NoError(t, err)
-
-		require.Equal(t, 1, success.NumRecordsImported)
+		require.Len(t, result.Chunks, 3)
+		require.Equal(t, 500, result.Chunks[0].EventsSent)
+		require.Equal(t, 500, result.Chunks[1].EventsSent)
+		require.Equal(t, 200, result.Chunks[2].EventsSent)
+		require.Equal(t, 1200, result.NumRecordsImported)
 	})
 
-	t.Run("can disable strict mode if requested", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+	t.Run("batch size keeps the client default options", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
 
-		setupHttpEndpointTest(t, mp, getValues(117, false), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(`{"code": 200,"num_records_imported": 1,"status": 1}`)),
-		})
+		var batches []int
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "1", req.URL.Query().Get("strict"))
+			require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+			reader, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
 
-		success, err := mp.Import(ctx, events, ImportOptions{
-			Strict:      false,
-			Compression: Gzip,
+			var r []*Event
+			require.NoError(t, json.NewDecoder(reader).Decode(&r))
+			batches = append(batches, len(r))
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
 		})
-		require.NoError(t, err)
 
-		require.Equal(t, 1, success.NumRecordsImported)
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithDefaultImportOptions(ImportOptionsRecommend))
+		_, err := mp.ImportChunked(ctx, newEvents(mp, 1200), ImportOptions{BatchSize: 500})
+		require.NoError(t, err)
+		require.Equal(t, []int{500, 500, 200}, batches)
 	})
 
-	t.Run("bad request", func(t *testing.T) {
-		ctx := context.Background()
+	t.Run("invalid batch size", func(t *testing.T) {
 		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
-			StatusCode: http.StatusBadRequest,
-			Body: io.NopCloser(strings.NewReader(`
-			{
-				"code": 400,
-				"status": "Bad Request",
-				"num_records_imported": 1,
-				"error": "some data points in the request failed validation",
-				"failed_records": [
-					{
-						"index": 1,
-						"field": "event",
-						"insert_id": "some-insert-id",
-						"message": "'event' must not be missing or blank"
-					}
-				]
+		_, err := mp.ImportChunked(ctx, newEvents(mp, 10), ImportOptions{BatchSize: MaxImportEvents + 1})
+		require.Error(t, err)
+		_, err = mp.ImportChunked(ctx, newEvents(mp, 10), ImportOptions{BatchSize: -1})
+		require.Error(t, err)
+	})
+
+	t.Run("order is preserved across chunks", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var sequence []float64
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			for _, e := range r {
+				sequence = append(sequence, e.Properties["sequence"].(float64))
 			}
-			`)),
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
 		})
 
-		_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
-		validationError := &ImportFailedValidationError{}
-		require.ErrorAs(t, err, validationError)
-		require.Equal(t, 1, validationError.NumRecordsImported)
-		require.Equal(t, 1, len(validationError.FailedImportRecords))
-		require.Equal(t, "some-insert-id", validationError.FailedImportRecords[0].InsertID)
-		require.Equal(t, "event", validationError.FailedImportRecords[0].Field)
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		events := make([]*Event, MaxImportEvents+5)
+		for i := range events {
+			events[i] = mp.NewEvent("import-event", "some-id", map[string]any{"sequence": i})
+		}
+
+		_, err := mp.ImportChunked(ctx, events, ImportOptions{})
+		require.NoError(t, err)
+		require.Len(t, sequence, len(events))
+		for i, seq := range sequence {
+			require.Equal(t, float64(i), seq)
+		}
 	})
 
-	t.Run("rate limit exceeded", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+	t.Run("keeps going after a failed chunk", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
 
-		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
-			StatusCode: http.StatusTooManyRequests,
-			Body: io.NopCloser(strings.NewReader(`
-			{
-				"code": 429,
-				"error":"rate limit exceeded",
-				"status": 0
+		calls := 0
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				return httpmock.NewStringResponse(http.StatusRequestEntityTooLarge, `{"code": 413, "error": "too large", "status": 0}`), nil
 			}
-			`)),
+			return httpmock.NewStringResponse(http.StatusOK, `{"code": 200,"num_records_imported": 10,"status": 1}`), nil
 		})
 
-		_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
-		rateLimitError := &ImportRateLimitError{}
-		require.ErrorAs(t, err, rateLimitError)
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		result, err := mp.ImportChunked(ctx, newEvents(mp, MaxImportEvents+10), ImportOptions{})
+		require.Error(t, err)
+		require.Len(t, result.Chunks, 2)
+		require.Error(t, result.Chunks[0].Err)
+		require.Equal(t, 0, result.Chunks[0].NumRecordsImported)
+		require.NoError(t, result.Chunks[1].Err)
+		require.Equal(t, 10, result.NumRecordsImported)
+		require.ErrorIs(t, err, result.Chunks[0].Err)
 	})
 
-	t.Run("test know status code errors", func(t *testing.T) {
-		tests := []struct {
-			httpStatusCode int
-		}{
-			{
-				httpStatusCode: http.StatusUnauthorized,
-			},
-			{
-				httpStatusCode: http.StatusRequestEntityTooLarge,
-			},
-		}
+	t.Run("stops after a rate limited chunk", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
 
-		for _, test := range tests {
-			t.Run(fmt.Sprintf("http status code %d", test.httpStatusCode), func(t *testing.T) {
-				ctx := context.Background()
-				mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-				setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
-					StatusCode: test.httpStatusCode,
-					Body: io.NopCloser(strings.NewReader(fmt.Sprintf(`
-					{
-						"code": %d,
-						"error":"Unauthorized",
-						"status": 0
-					  }
-					`, test.httpStatusCode))),
-				})
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, httpmock.NewStringResponder(http.StatusTooManyRequests, `{"code": 429, "error": "rate limited", "status": 0}`))
 
-				_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
-				genericError := &ImportGenericError{}
-				require.ErrorAs(t, err, genericError)
-				require.Equal(t, test.httpStatusCode, genericError.Code)
-			})
-		}
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		result, err := mp.ImportChunked(ctx, newEvents(mp, 2*MaxImportEvents+10), ImportOptions{})
+		require.Error(t, err)
+		require.ErrorAs(t, err, &ImportRateLimitError{})
+		require.Len(t, result.Chunks, 1)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
 	})
 
-	t.Run("unknown status code", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
-		events := []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}
+	t.Run("stops when the context is done", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
 
-		setupHttpEndpointTest(t, mp, getValues(117, false), func(r []*Event) {
-			require.Equal(t, events, r)
-		}, &http.Response{
-			StatusCode: http.StatusTeapot,
-			Body:       io.NopCloser(strings.NewReader("i'm a teapot")),
-		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-		_, err := mp.Import(ctx, events, ImportOptions{
-			Strict:      false,
-			Compression: Gzip,
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, MaxImportEvents)), nil
 		})
-		require.Error(t, err)
+
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		result, err := mp.ImportChunked(ctx, newEvents(mp, 2*MaxImportEvents+10), ImportOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Len(t, result.Chunks, 1)
+		require.Equal(t, MaxImportEvents, result.NumRecordsImported)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
 	})
 
-	t.Run("don't send more events then allowed", func(t *testing.T) {
-		ctx := context.Background()
-		mp := NewApiClient("token")
-		var events []*Event
-		for i := 0; i < MaxImportEvents+1; i++ {
-			events = append(events, mp.NewEvent("some event", EmptyDistinctID, map[string]any{}))
+	t.Run("events sent doesn't count filtered events", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, usEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(r))), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"), WithEventFilter(func(e *Event) bool {
+			return e.Name != "dropped"
+		}))
+		events := newEvents(mp, 10)
+		for _, e := range events[:4] {
+			e.Name = "dropped"
 		}
 
-		_, err := mp.Import(ctx, events, ImportOptionsRecommend)
-		require.Error(t, err)
+		result, err := mp.ImportChunked(ctx, events, ImportOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []ImportChunkResult{
+			{Index: 0, EventsSent: 6, NumRecordsImported: 6},
+		}, result.Chunks)
 	})
 }
 
@@ -563,6 +1815,14 @@ func TestPeopleProperties(t *testing.T) {
 		require.Equal(t, "some-email", props.Properties["$email"])
 	})
 
+	t.Run("can set created", func(t *testing.T) {
+		created := time.Date(2023, 5, 24, 18, 5, 35, 123456789, time.FixedZone("UTC+2", 2*60*60))
+
+		props := NewPeopleProperties("some-id", map[string]any{})
+		props.SetCreated(created)
+		require.Equal(t, "2023-05-24T16:05:35", props.Properties[string(PeopleCreatedProperty)])
+	})
+
 	t.Run("can set ip property", func(t *testing.T) {
 		ip := net.ParseIP("10.1.1.117")
 		require.NotNil(t, ip)
@@ -608,6 +1868,39 @@ func TestPeopleProperties(t *testing.T) {
 		})
 		require.Equal(t, "0", props.shouldGeoLookupIp())
 	})
+
+	t.Run("merge overwrites", func(t *testing.T) {
+		props := NewPeopleProperties("some-id", map[string]any{"plan": "free", "seats": 1})
+		require.NoError(t, props.Merge(map[string]any{"plan": "pro", "company": "acme"}, MergeOverwrite))
+		require.Equal(t, map[string]any{"plan": "pro", "seats": 1, "company": "acme"}, props.Properties)
+	})
+
+	t.Run("merge keeps existing", func(t *testing.T) {
+		props := NewPeopleProperties("some-id", map[string]any{"plan": "free", "seats": 1})
+		require.NoError(t, props.Merge(map[string]any{"plan": "pro", "company": "acme"}, MergeKeepExisting))
+		require.Equal(t, map[string]any{"plan": "free", "seats": 1, "company": "acme"}, props.Properties)
+	})
+
+	t.Run("merge errors on conflict", func(t *testing.T) {
+		props := NewPeopleProperties("some-id", map[string]any{"plan": "free", "seats": 1})
+		err := props.Merge(map[string]any{"plan": "pro", "seats": 1, "company": "acme"}, MergeError)
+
+		var conflict MergeConflictError
+		require.ErrorAs(t, err, &conflict)
+		require.Equal(t, []string{"plan"}, conflict.Properties)
+		require.Equal(t, map[string]any{"plan": "free", "seats": 1}, props.Properties)
+
+		require.NoError(t, props.Merge(map[string]any{"seats": 1, "company": "acme"}, MergeError))
+		require.Equal(t, map[string]any{"plan": "free", "seats": 1, "company": "acme"}, props.Properties)
+	})
+
+	t.Run("server properties never geo lookup", func(t *testing.T) {
+		props := NewServerPeopleProperties("some-id", nil)
+		require.Equal(t, "0", props.shouldGeoLookupIp())
+
+		props.SetIp(net.ParseIP("10.1.1.117"), UseRequestIp())
+		require.Equal(t, "0", props.shouldGeoLookupIp())
+	})
 }
 
 func setupPeopleAndGroupsEndpoint(t *testing.T, client *ApiClient, endpoint string, testPayload func(body io.Reader), httpResponse *http.Response) {
@@ -752,7 +2045,65 @@ func TestPeopleSet(t *testing.T) {
 	})
 }
 
+func TestPeopleDuplicateDistinctIDs(t *testing.T) {
+	people := []*PeopleProperties{
+		NewPeopleProperties("some-id-1", map[string]any{}),
+		NewPeopleProperties("some-id-2", map[string]any{}),
+		NewPeopleProperties("some-id-1", map[string]any{}),
+		NewPeopleProperties("some-id-2", map[string]any{}),
+		NewPeopleProperties("some-id-1", map[string]any{}),
+	}
+
+	t.Run("rejects duplicates when enabled", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithDuplicateDistinctIDCheck())
+
+		for _, call := range []func(context.Context, []*PeopleProperties) error{mp.PeopleSet, mp.PeopleSetOnce} {
+			err := call(ctx, people)
+			duplicateErr := &DuplicateDistinctIDError{}
+			require.ErrorAs(t, err, duplicateErr)
+			require.Equal(t, []string{"some-id-1", "some-id-2"}, duplicateErr.DistinctIDs)
+		}
+	})
+
+	t.Run("allows duplicates by default", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		setupPeopleAndGroupsEndpoint(t, mp, peopleSetURL, func(body io.Reader) {}, peopleAndGroupSuccess())
+		require.NoError(t, mp.PeopleSet(ctx, people))
+	})
+}
+
 func TestPeopleSetOnce(t *testing.T) {
+	t.Run("geo lookup matches set", func(t *testing.T) {
+		ip := net.ParseIP("10.1.1.117")
+		for _, people := range []*PeopleProperties{
+			NewPeopleProperties("some-id", nil),
+			NewPeopleProperties("some-id", map[string]any{string(PeopleGeolocationByIpProperty): ip.String()}),
+			NewServerPeopleProperties("some-id", map[string]any{string(PeopleGeolocationByIpProperty): ip.String()}),
+		} {
+			var setIP, setOnceIP string
+
+			mp := NewApiClient("token")
+			setupPeopleAndGroupsEndpoint(t, mp, peopleSetURL, func(body io.Reader) {
+				payload := []*peopleSetPayload{}
+				require.NoError(t, json.NewDecoder(body).Decode(&payload))
+				setIP = payload[0].IP
+			}, peopleAndGroupSuccess())
+			require.NoError(t, mp.PeopleSet(context.Background(), []*PeopleProperties{people}))
+
+			setupPeopleAndGroupsEndpoint(t, mp, peopleSetOnceURL, func(body io.Reader) {
+				payload := []*peopleSetOncePayload{}
+				require.NoError(t, json.NewDecoder(body).Decode(&payload))
+				setOnceIP = payload[0].IP
+			}, peopleAndGroupSuccess())
+			require.NoError(t, mp.PeopleSetOnce(context.Background(), []*PeopleProperties{people}))
+
+			require.Equal(t, setIP, setOnceIP)
+		}
+	})
+
 	t.Run("can set one", func(t *testing.T) {
 		ctx := context.Background()
 
@@ -871,6 +2222,7 @@ func TestPeopleUnion(t *testing.T) {
 		payload := arrayPayload[0]
 		require.Equal(t, mp.token, payload.Token)
 		require.Equal(t, "some-id", payload.DistinctID)
+		require.Equal(t, noGeoLookupIp, payload.IP)
 		require.Equal(t, "some-value", payload.Union["some-prop"])
 
 	}, peopleAndGroupSuccess())
@@ -891,6 +2243,7 @@ func TestPeoplePeopleIncrement(t *testing.T) {
 		payload := arrayPayload[0]
 		require.Equal(t, mp.token, payload.Token)
 		require.Equal(t, "some-id", payload.DistinctID)
+		require.Equal(t, noGeoLookupIp, payload.IP)
 		require.Equal(t, 1, payload.Add["some-prop"])
 
 	}, peopleAndGroupSuccess())
@@ -911,6 +2264,7 @@ func TestPeopleAppendListProperty(t *testing.T) {
 		payload := arrayPayload[0]
 		require.Equal(t, mp.token, payload.Token)
 		require.Equal(t, "some-id", payload.DistinctID)
+		require.Equal(t, noGeoLookupIp, payload.IP)
 		require.Equal(t, "some-value", payload.Append["some-prop"])
 
 	}, peopleAndGroupSuccess())
@@ -931,6 +2285,7 @@ func TestPeopleRemoveListProperty(t *testing.T) {
 		payload := arrayPayload[0]
 		require.Equal(t, mp.token, payload.Token)
 		require.Equal(t, "some-id", payload.DistinctID)
+		require.Equal(t, noGeoLookupIp, payload.IP)
 		require.Equal(t, "some-value", payload.Remove["some-prop"])
 
 	}, peopleAndGroupSuccess())
@@ -943,19 +2298,40 @@ func TestPeopleRemoveListProperty(t *testing.T) {
 func TestPeopleDeleteProperty(t *testing.T) {
 	ctx := context.Background()
 
-	mp := NewApiClient("token")
-	setupPeopleAndGroupsEndpoint(t, mp, peopleDeletePropertyUrl, func(body io.Reader) {
-		arrayPayload := []*peopleDeletePropertyPayload{}
-		require.NoError(t, json.NewDecoder(body).Decode(&arrayPayload))
+	t.Run("can delete properties", func(t *testing.T) {
+		mp := NewApiClient("token")
+		setupPeopleAndGroupsEndpoint(t, mp, peopleDeletePropertyUrl, func(body io.Reader) {
+			arrayPayload := []*peopleDeletePropertyPayload{}
+			require.NoError(t, json.NewDecoder(body).Decode(&arrayPayload))
 
-		payload := arrayPayload[0]
-		require.Equal(t, mp.token, payload.Token)
-		require.Equal(t, "some-id", payload.DistinctID)
-		require.Equal(t, []string{"some-value"}, payload.Unset)
+			payload := arrayPayload[0]
+			require.Equal(t, mp.token, payload.Token)
+			require.Equal(t, "some-id", payload.DistinctID)
+			require.Equal(t, noGeoLookupIp, payload.IP)
+			require.Equal(t, []string{"some-value"}, payload.Unset)
 
-	}, peopleAndGroupSuccess())
+		}, peopleAndGroupSuccess())
+
+		require.NoError(t, mp.PeopleDeleteProperty(ctx, "some-id", []string{"some-value"}))
+	})
 
-	require.NoError(t, mp.PeopleDeleteProperty(ctx, "some-id", []string{"some-value"}))
+	t.Run("can delete properties variadic", func(t *testing.T) {
+		mp := NewApiClient("token")
+		setupPeopleAndGroupsEndpoint(t, mp, peopleDeletePropertyUrl, func(body io.Reader) {
+			arrayPayload := []*peopleDeletePropertyPayload{}
+			require.NoError(t, json.NewDecoder(body).Decode(&arrayPayload))
+
+			require.Equal(t, []string{"some-value-1", "some-value-2"}, arrayPayload[0].Unset)
+		}, peopleAndGroupSuccess())
+
+		require.NoError(t, mp.PeopleDeletePropertyVariadic(ctx, "some-id", "some-value-1", "some-value-2"))
+	})
+
+	t.Run("empty unset returns an error", func(t *testing.T) {
+		mp := NewApiClient("token")
+		require.ErrorIs(t, mp.PeopleDeleteProperty(ctx, "some-id", nil), ErrNoPropertiesToUnset)
+		require.ErrorIs(t, mp.PeopleDeletePropertyVariadic(ctx, "some-id"), ErrNoPropertiesToUnset)
+	})
 }
 
 func TestPeopleDeleteProfile(t *testing.T) {
@@ -1096,3 +2472,81 @@ func TestGroupDelete(t *testing.T) {
 
 	require.NoError(t, mp.GroupDelete(ctx, "group-key", "group-id"))
 }
+
+func TestGroupBatchUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("groups each operation into one request", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		requests := map[string][]map[string]any{}
+		for _, u := range []string{groupSetUrl, groupsSetOnceUrl, groupsUnionListPropertyUrl, groupsRemoveFromListPropertyUrl, groupsDeletePropertyUrl} {
+			u := u
+			httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, u), func(req *http.Request) (*http.Response, error) {
+				var payload []map[string]any
+				require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+				requests[u] = append(requests[u], payload...)
+				return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+			})
+		}
+
+		mp := NewApiClient("token")
+		require.NoError(t, mp.GroupBatchUpdate(ctx, "company_id", []GroupOps{
+			{
+				GroupID: "acme",
+				Set:     map[string]any{"plan": "pro"},
+				Union:   map[string]any{"regions": []string{"eu"}},
+				Unset:   []string{"trial"},
+			},
+			{
+				GroupID: "globex",
+				Set:     map[string]any{"plan": "free"},
+				SetOnce: map[string]any{"created": "2023-01-01"},
+				Remove:  map[string]any{"regions": "us"},
+			},
+		}))
+
+		require.Equal(t, 5, httpmock.GetTotalCallCount())
+		require.Len(t, requests[groupSetUrl], 2)
+		require.Equal(t, "acme", requests[groupSetUrl][0]["$group_id"])
+		require.Equal(t, "company_id", requests[groupSetUrl][0]["$group_key"])
+		require.Equal(t, map[string]any{"plan": "pro"}, requests[groupSetUrl][0]["$set"])
+		require.Equal(t, "globex", requests[groupSetUrl][1]["$group_id"])
+
+		require.Len(t, requests[groupsSetOnceUrl], 1)
+		require.Equal(t, "globex", requests[groupsSetOnceUrl][0]["$group_id"])
+		require.Equal(t, map[string]any{"created": "2023-01-01"}, requests[groupsSetOnceUrl][0]["$set_once"])
+
+		require.Len(t, requests[groupsUnionListPropertyUrl], 1)
+		require.Equal(t, map[string]any{"regions": []any{"eu"}}, requests[groupsUnionListPropertyUrl][0]["$union"])
+
+		require.Len(t, requests[groupsRemoveFromListPropertyUrl], 1)
+		require.Equal(t, map[string]any{"regions": "us"}, requests[groupsRemoveFromListPropertyUrl][0]["$remove"])
+
+		require.Len(t, requests[groupsDeletePropertyUrl], 1)
+		require.Equal(t, []any{"trial"}, requests[groupsDeletePropertyUrl][0]["$unset"])
+	})
+
+	t.Run("chunks to the api limit", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var batches []int
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, groupSetUrl), func(req *http.Request) (*http.Response, error) {
+			var payload []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			batches = append(batches, len(payload))
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		updates := make([]GroupOps, MaxPeopleEvents+1)
+		for i := range updates {
+			updates[i] = GroupOps{GroupID: strconv.Itoa(i), Set: map[string]any{"plan": "pro"}}
+		}
+
+		mp := NewApiClient("token")
+		require.NoError(t, mp.GroupBatchUpdate(ctx, "company_id", updates))
+		require.Equal(t, []int{MaxPeopleEvents, 1}, batches)
+	})
+}
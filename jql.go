@@ -0,0 +1,96 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const jqlUrl = "/api/2.0/jql"
+
+// JQLStream calls the JQL API and streams each row of the result array through the returned channel
+// https://developer.mixpanel.com/reference/jql
+// The channel is closed once all rows are read, when ctx is done or when the stream fails
+// The returned func waits for the channel to close and returns why the stream stopped early, nil if all rows were read
+// Cancel ctx when you stop reading before the channel is closed, otherwise the response body
+// and any WithMaxConcurrentRequests slot are held open
+func (a *ApiClient) JQLStream(ctx context.Context, script string, params map[string]any) (<-chan json.RawMessage, func() error, error) {
+	form := url.Values{}
+	form.Add("script", script)
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to json encode params: %w", err)
+		}
+		form.Add("params", string(p))
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodPost,
+		a.queryEndpoint+jqlUrl,
+		strings.NewReader(form.Encode()),
+		a.exportServiceAccount(), acceptJson(), applicationFormData(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query jql: %w", err)
+	}
+
+	if httpResponse.StatusCode != http.StatusOK {
+		defer httpResponse.Body.Close()
+		return nil, nil, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+
+	dec := json.NewDecoder(httpResponse.Body)
+	token, err := dec.Token()
+	if err != nil {
+		httpResponse.Body.Close()
+		return nil, nil, fmt.Errorf("failed to json decode response body: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		httpResponse.Body.Close()
+		return nil, nil, fmt.Errorf("expected a json array, got %v", token)
+	}
+
+	rows := make(chan json.RawMessage)
+	done := make(chan struct{})
+	var streamErr error
+	go func() {
+		defer close(done)
+		defer close(rows)
+		defer httpResponse.Body.Close()
+
+		streamErr = streamJQLRows(ctx, dec, rows)
+	}()
+
+	wait := func() error {
+		<-done
+		return streamErr
+	}
+	return rows, wait, nil
+}
+
+// streamJQLRows sends each row of the result array to rows
+func streamJQLRows(ctx context.Context, dec *json.Decoder, rows chan<- json.RawMessage) error {
+	for dec.More() {
+		var row json.RawMessage
+		if err := dec.Decode(&row); err != nil {
+			return fmt.Errorf("failed to decode jql row: %w", err)
+		}
+
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// More also returns false when the body is cut off, only the closing bracket means every row was read
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to decode jql row: %w", err)
+	}
+	return nil
+}
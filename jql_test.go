@@ -0,0 +1,109 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJQLStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("streams rows", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, jqlUrl), func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, req.ParseForm())
+			require.Equal(t, "function main() { return Events(params) }", req.PostForm.Get("script"))
+			require.JSONEq(t, `{"from_date": "2023-01-01"}`, req.PostForm.Get("params"))
+
+			return httpmock.NewStringResponse(http.StatusOK, `[{"name": "a"}, {"name": "b"}, 3]`), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		rows, wait, err := mp.JQLStream(ctx, "function main() { return Events(params) }", map[string]any{"from_date": "2023-01-01"})
+		require.NoError(t, err)
+
+		var results []json.RawMessage
+		for row := range rows {
+			results = append(results, row)
+		}
+		require.Equal(t, []json.RawMessage{
+			json.RawMessage(`{"name": "a"}`),
+			json.RawMessage(`{"name": "b"}`),
+			json.RawMessage(`3`),
+		}, results)
+		require.NoError(t, wait())
+	})
+
+	t.Run("reports a stream cut off partway", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, jqlUrl), httpmock.NewStringResponder(http.StatusOK, `[{"name": "a"}, {"na`))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		rows, wait, err := mp.JQLStream(ctx, "function main() {}", nil)
+		require.NoError(t, err)
+
+		var results []json.RawMessage
+		for row := range rows {
+			results = append(results, row)
+		}
+		require.Len(t, results, 1)
+		require.ErrorIs(t, wait(), io.ErrUnexpectedEOF)
+	})
+
+	t.Run("reports a stream cut off between rows", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, jqlUrl), httpmock.NewStringResponder(http.StatusOK, `[1, 2`))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		rows, wait, err := mp.JQLStream(ctx, "function main() {}", nil)
+		require.NoError(t, err)
+
+		for range rows {
+		}
+		require.Error(t, wait())
+	})
+
+	t.Run("stops on context cancellation", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, jqlUrl), httpmock.NewStringResponder(http.StatusOK, `[1, 2, 3]`))
+
+		ctx, cancel := context.WithCancel(ctx)
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		rows, wait, err := mp.JQLStream(ctx, "function main() {}", nil)
+		require.NoError(t, err)
+
+		require.Equal(t, json.RawMessage(`1`), <-rows)
+		cancel()
+		require.ErrorIs(t, wait(), context.Canceled)
+		_, ok := <-rows
+		require.False(t, ok)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usQueryEndpoint, jqlUrl), httpmock.NewStringResponder(http.StatusBadRequest, "bad script"))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, _, err := mp.JQLStream(ctx, "function main() {", nil)
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, http.StatusBadRequest, httpErr.Status)
+	})
+}
@@ -2,24 +2,44 @@ package mixpanel
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 )
 
 const (
 	version = "v1.2.0"
 
-	usEndpoint     = "https://api.mixpanel.com"
-	usDataEndpoint = "https://data.mixpanel.com"
+	usEndpoint      = "https://api.mixpanel.com"
+	usDataEndpoint  = "https://data.mixpanel.com"
+	usQueryEndpoint = "https://mixpanel.com"
 
-	euEndpoint     = "https://api-eu.mixpanel.com"
-	euDataEndpoint = "https://data-eu.mixpanel.com"
+	euEndpoint      = "https://api-eu.mixpanel.com"
+	euDataEndpoint  = "https://data-eu.mixpanel.com"
+	euQueryEndpoint = "https://eu.mixpanel.com"
 
 	EmptyDistinctID = ""
 
+	// DefaultInstanceIDProperty is the event property WithInstanceID uses
+	DefaultInstanceIDProperty = "$mp_instance_id"
+
+	// ConsentProperty is the event property WithConsent uses
+	ConsentProperty = "consent"
+	// ConsentHeader is the ingestion request header WithConsent uses
+	ConsentHeader = "X-Consent"
+
 	propertyToken      = "token"
 	propertyDistinctID = "distinct_id"
 
@@ -30,7 +50,11 @@ const (
 	goLib              = "go"
 	propertyLibVersion = "$lib_version"
 
+	propertyReferrer        = "$referrer"
+	propertyReferringDomain = "$referring_domain"
+
 	acceptHeader               = "Accept"
+	acceptEncodingHeader       = "Accept-Encoding"
 	acceptPlainTextHeader      = "text/plain"
 	acceptJsonHeader           = "application/json"
 	contentEncodingHeader      = "Content-Encoding"
@@ -65,12 +89,41 @@ type Ingestion interface {
 
 var _ Ingestion = (*ApiClient)(nil)
 
+// AdvancedIngestion is the ingestion methods added after Ingestion, kept apart so implementations of Ingestion still compile
+type AdvancedIngestion interface {
+	// Events
+	TrackOne(ctx context.Context, event *Event) error
+	TrackWithResult(ctx context.Context, events []*Event) (*TrackResult, error)
+	TrackIsolated(ctx context.Context, events []*Event) ([]*Event, error)
+	ImportOne(ctx context.Context, event *Event, options ImportOptions) (*ImportSuccess, error)
+	ImportChannel(ctx context.Context, ch <-chan *Event, options ImportOptions) (*ImportSuccess, error)
+	ImportChunked(ctx context.Context, events []*Event, options ImportOptions) (*DetailedImportResult, error)
+
+	// People
+	PeopleDeletePropertyVariadic(ctx context.Context, distinctID string, props ...string) error
+
+	// Groups
+	GroupBatchUpdate(ctx context.Context, groupKey string, updates []GroupOps) error
+}
+
+var _ AdvancedIngestion = (*ApiClient)(nil)
+
 type Export interface {
 	Export(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string) ([]*Event, error)
 }
 
 var _ Export = (*ApiClient)(nil)
 
+// AdvancedExport is the export methods added after Export
+type AdvancedExport interface {
+	ExportWithOptions(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, options ...ExportOption) ([]*Event, error)
+	ExportMapped(ctx context.Context, fromDate, toDate time.Time, limit int, event, where string, mapper ExportMapper, options ...ExportOption) ([]*Event, error)
+	ExportByUser(ctx context.Context, fromDate, toDate time.Time, event, where string, options ...ExportOption) (map[string][]*Event, error)
+	DownloadExportToFile(ctx context.Context, path string, fromDate, toDate time.Time, opts DownloadExportOptions, options ...ExportOption) (int64, error)
+}
+
+var _ AdvancedExport = (*ApiClient)(nil)
+
 type Identity interface {
 	Alias(ctx context.Context, distinctID, aliasID string) error
 	Merge(ctx context.Context, distinctID1, distinctID2 string) error
@@ -78,6 +131,21 @@ type Identity interface {
 
 var _ Identity = (*ApiClient)(nil)
 
+// AdvancedIdentity is the identity methods added after Identity
+type AdvancedIdentity interface {
+	MergeBatch(ctx context.Context, pairs [][2]string) error
+	IdentifyBatch(ctx context.Context, pairs []IdentifyPair) error
+}
+
+var _ AdvancedIdentity = (*ApiClient)(nil)
+
+type Engage interface {
+	PeopleGetOne(ctx context.Context, distinctID string) (map[string]any, bool, error)
+	PeopleQuery(ctx context.Context, where *WhereBuilder) ([]PeopleProfile, error)
+}
+
+var _ Engage = (*ApiClient)(nil)
+
 // Api is all the API's in the Mixpanel docs
 // https://developer.mixpanel.com/reference/overview
 type Api interface {
@@ -86,15 +154,39 @@ type Api interface {
 	Identity
 }
 
+// AdvancedApi is Api with the methods added after it
+type AdvancedApi interface {
+	Api
+	AdvancedIngestion
+	AdvancedExport
+	AdvancedIdentity
+	Engage
+}
+
+var _ AdvancedApi = (*ApiClient)(nil)
+
 type serviceAccount struct {
-	Username string
-	Secret   string
+	Username  string
+	Secret    string
+	ExpiresAt time.Time
+}
+
+// DefaultServiceAccountExpiryWarning is how long before expiry the client starts warning about the service account
+const DefaultServiceAccountExpiryWarning = 7 * 24 * time.Hour
+
+// Logger reports warnings from the client, *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...any)
 }
 
 type ApiClient struct {
-	client       *http.Client
-	apiEndpoint  string
-	dataEndpoint string
+	// droppedEvents is accessed atomically and kept first for 64-bit alignment
+	droppedEvents uint64
+
+	client        *http.Client
+	apiEndpoint   string
+	dataEndpoint  string
+	queryEndpoint string
 
 	projectID int
 	token     string
@@ -102,6 +194,52 @@ type ApiClient struct {
 
 	serviceAccount *serviceAccount
 	debugHttpCall  *debugHttpCalls
+
+	distinctIDKey string
+	tokenKey      string
+
+	instanceID    string
+	instanceIDKey string
+
+	customHttpClient   bool
+	transportOptions   []func(transport *http.Transport)
+	connectionPool     *connectionPool
+	insecureSkipVerify bool
+
+	eventEnrichers []EventEnricher
+	eventFilters   []EventFilter
+	deadLetter     DeadLetterFunc
+	beforeSend     BeforeSendFunc
+
+	requestSemaphore chan struct{}
+
+	trackCompression MpCompression
+
+	rejectDuplicateDistinctIDs bool
+
+	responseBodyLimit int64
+
+	maxPropertyStringLength int
+	rejectLongProperties    bool
+
+	timePropertyFormat   TimeFormat
+	nonFiniteFloatPolicy NonFiniteFloatPolicy
+
+	schema               *Schema
+	schemaValidationMode ValidationMode
+
+	defaultQueryParams url.Values
+
+	defaultImportOptions *ImportOptions
+
+	consent       string
+	consentTarget ConsentTarget
+
+	rateLimitObserver func(info RateLimitInfo)
+
+	logger                      Logger
+	serviceAccountExpiryWarning time.Duration
+	serviceAccountExpiryOnce    sync.Once
 }
 
 type Options func(mixpanel *ApiClient)
@@ -116,16 +254,38 @@ func ApiSecret(apiSecret string) Options {
 func HttpClient(client *http.Client) Options {
 	return func(mixpanel *ApiClient) {
 		mixpanel.client = client
+		mixpanel.customHttpClient = true
+	}
+}
+
+// Region is the data residency of a mixpanel project
+type Region int
+
+const (
+	RegionUS Region = iota
+	RegionEU
+)
+
+// WithRegion sets the api, data and query endpoints for the project's data residency
+func WithRegion(region Region) Options {
+	return func(mixpanel *ApiClient) {
+		switch region {
+		case RegionEU:
+			mixpanel.apiEndpoint = euEndpoint
+			mixpanel.dataEndpoint = euDataEndpoint
+			mixpanel.queryEndpoint = euQueryEndpoint
+		default:
+			mixpanel.apiEndpoint = usEndpoint
+			mixpanel.dataEndpoint = usDataEndpoint
+			mixpanel.queryEndpoint = usQueryEndpoint
+		}
 	}
 }
 
 // EuResidency sets the mixpanel client to use the eu endpoints
 // Use for EU Projects
 func EuResidency() Options {
-	return func(mixpanel *ApiClient) {
-		mixpanel.apiEndpoint = euEndpoint
-		mixpanel.dataEndpoint = euDataEndpoint
-	}
+	return WithRegion(RegionEU)
 }
 
 // ProxyApiLocation sets the mixpanel client to use the custom location for all ingestion requests
@@ -144,6 +304,14 @@ func ProxyDataLocation(proxy string) Options {
 	}
 }
 
+// ProxyQueryLocation sets the mixpanel client to use the custom location for all query requests
+// Example: http://locahosthost:8080
+func ProxyQueryLocation(proxy string) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.queryEndpoint = proxy
+	}
+}
+
 // ServiceAccount add a service account to the mixpanel client
 // https://developer.mixpanel.com/reference/service-accounts-api
 func ServiceAccount(projectID int, username, secret string) Options {
@@ -156,6 +324,236 @@ func ServiceAccount(projectID int, username, secret string) Options {
 	}
 }
 
+type connectionPool struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// WithConnectionPool tunes the connection pool of the internal http transport
+// It has no effect when a custom client is provided with HttpClient
+func WithConnectionPool(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.connectionPool = &connectionPool{
+			MaxIdleConns:        maxIdle,
+			MaxIdleConnsPerHost: maxIdlePerHost,
+			IdleConnTimeout:     idleTimeout,
+		}
+		mixpanel.transportOptions = append(mixpanel.transportOptions, func(transport *http.Transport) {
+			transport.MaxIdleConns = maxIdle
+			transport.MaxIdleConnsPerHost = maxIdlePerHost
+			transport.IdleConnTimeout = idleTimeout
+		})
+	}
+}
+
+// WithInsecureSkipVerify DISABLES TLS certificate verification on the internal http transport
+// DANGER: only for local testing against a proxy or mock server with a self signed certificate, never use in production
+// It has no effect when a custom client is provided with HttpClient
+func WithInsecureSkipVerify() Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.insecureSkipVerify = true
+		mixpanel.transportOptions = append(mixpanel.transportOptions, func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.InsecureSkipVerify = true
+		})
+	}
+}
+
+// EventEnricher mutates an event right before it is sent
+type EventEnricher func(ctx context.Context, event *Event)
+
+// WithEventEnricher registers an enricher that is called for each event in Track and Import before sending
+// Enrichers run in registration order and have access to the request context
+func WithEventEnricher(enricher EventEnricher) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.eventEnrichers = append(mixpanel.eventEnrichers, enricher)
+	}
+}
+
+// EventFilter decides if an event is sent, returning false drops the event
+type EventFilter func(event *Event) bool
+
+// WithEventFilter registers a filter that is called for each event in Track and Import before sending
+// Dropped events are counted in DroppedEvents
+func WithEventFilter(filter EventFilter) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.eventFilters = append(mixpanel.eventFilters, filter)
+	}
+}
+
+// DeadLetterFunc receives a batch of events that failed to send and the cause
+type DeadLetterFunc func(ctx context.Context, events []*Event, cause error)
+
+// WithDeadLetter registers a func that is called with the batch and error whenever Track or Import fails to send
+// Use it to persist the events somewhere for a later replay
+func WithDeadLetter(deadLetter DeadLetterFunc) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.deadLetter = deadLetter
+	}
+}
+
+// BeforeSendFunc inspects a batch just before it is sent to endpoint, returning an error aborts the send
+type BeforeSendFunc func(ctx context.Context, endpoint string, events []*Event) error
+
+// WithBeforeSend registers a func that is called with every Track and Import batch before it is sent
+// A non nil error aborts the request and is returned to the caller, e.g. for a kill switch or sampling
+// Aborted batches are not passed to the dead letter func
+func WithBeforeSend(beforeSend BeforeSendFunc) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.beforeSend = beforeSend
+	}
+}
+
+// WithPropertyTruncation truncates string property values longer than maxStringLen bytes before sending
+// Truncated values end with "..."
+func WithPropertyTruncation(maxStringLen int) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.maxPropertyStringLength = maxStringLen
+		mixpanel.rejectLongProperties = false
+	}
+}
+
+// WithPropertyValidation rejects Track and Import calls with string property values longer than maxStringLen bytes
+// A PropertyLimitError listing the offending properties is returned
+func WithPropertyValidation(maxStringLen int) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.maxPropertyStringLength = maxStringLen
+		mixpanel.rejectLongProperties = true
+	}
+}
+
+// WithTimePropertyFormat converts time.Time property values on events, profiles and groups before sending
+// By default encoding/json sends them as RFC3339 strings which mixpanel does not treat as dates
+func WithTimePropertyFormat(format TimeFormat) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.timePropertyFormat = format
+	}
+}
+
+// WithDefaultQueryParams adds query params to every Track, Import, People, Group and Identity request
+// Use for gateways that route on a query param, params set by the sdk (e.g. verbose, strict, project_id) are never replaced
+func WithDefaultQueryParams(params url.Values) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.defaultQueryParams = params
+	}
+}
+
+// WithDefaultImportOptions sets the options Import uses when it is called with zero value ImportOptions
+// Options passed to Import are used as is
+func WithDefaultImportOptions(options ImportOptions) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.defaultImportOptions = &options
+	}
+}
+
+// WithRateLimitObserver calls observer with the rate limit headers of every response that has them
+// Use it to slow down before mixpanel starts returning 429s
+func WithRateLimitObserver(observer func(info RateLimitInfo)) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.rateLimitObserver = observer
+	}
+}
+
+// WithNonFiniteFloatPolicy sets what Track and Import do with NaN and infinite property values
+// By default the call fails with a NonFiniteFloatError naming the properties
+func WithNonFiniteFloatPolicy(policy NonFiniteFloatPolicy) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.nonFiniteFloatPolicy = policy
+	}
+}
+
+// WithSchemaValidation checks the properties of events declared in schema before Track and Import send them
+// Undeclared properties and type mismatches are logged or rejected depending on mode, see GetSchema
+func WithSchemaValidation(schema *Schema, mode ValidationMode) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.schema = schema
+		mixpanel.schemaValidationMode = mode
+	}
+}
+
+// ConsentTarget is where WithConsent sends the consent signal
+type ConsentTarget int
+
+const (
+	// ConsentAsProperty sets the ConsentProperty on every tracked and imported event
+	ConsentAsProperty ConsentTarget = 1 << iota
+	// ConsentAsHeader sets the ConsentHeader on every Track and Import request
+	ConsentAsHeader
+
+	ConsentAsPropertyAndHeader = ConsentAsProperty | ConsentAsHeader
+)
+
+// WithConsent attaches a consent string (e.g. a do not sell flag or a consent string) to Track and Import
+// Events that already have the ConsentProperty keep their own value
+func WithConsent(consent string, target ConsentTarget) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.consent = consent
+		mixpanel.consentTarget = target
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of requests the client has in flight at once
+// Requests over the limit wait for a slot or until their context is done
+func WithMaxConcurrentRequests(n int) Options {
+	return func(mixpanel *ApiClient) {
+		if n > 0 {
+			mixpanel.requestSemaphore = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithTrackCompression sets the compression used for the body of Track requests
+func WithTrackCompression(compression MpCompression) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.trackCompression = compression
+	}
+}
+
+// WithDuplicateDistinctIDCheck makes PeopleSet and PeopleSetOnce return a DuplicateDistinctIDError
+// when the same distinct id appears more than once in a batch instead of letting the last write win
+func WithDuplicateDistinctIDCheck() Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.rejectDuplicateDistinctIDs = true
+	}
+}
+
+// WithResponseBodyLimit caps how many bytes of an error response body are kept in a HttpError
+// Defaults to DefaultResponseBodyLimit, which is also used for a limit of 0 or less
+func WithResponseBodyLimit(limit int64) Options {
+	return func(mixpanel *ApiClient) {
+		if limit <= 0 {
+			limit = DefaultResponseBodyLimit
+		}
+		mixpanel.responseBodyLimit = limit
+	}
+}
+
+// ServiceAccountWithExpiry add a service account with its expiry date to the mixpanel client
+// Requests made close to the expiry log a warning, see WithServiceAccountExpiryWarning
+func ServiceAccountWithExpiry(projectID int, username, secret string, expiresAt time.Time) Options {
+	return func(mixpanel *ApiClient) {
+		ServiceAccount(projectID, username, secret)(mixpanel)
+		mixpanel.serviceAccount.ExpiresAt = expiresAt
+	}
+}
+
+// WithServiceAccountExpiryWarning sets how long before the service account expires the client starts warning
+func WithServiceAccountExpiryWarning(within time.Duration) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.serviceAccountExpiryWarning = within
+	}
+}
+
+// WithLogger sets the logger used to report warnings
+func WithLogger(logger Logger) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.logger = logger
+	}
+}
+
 // DebugHttpCalls streams payload information and url information for debugging purposes
 func DebugHttpCalls(writer io.Writer) Options {
 	return func(mixpanel *ApiClient) {
@@ -165,31 +563,222 @@ func DebugHttpCalls(writer io.Writer) Options {
 	}
 }
 
+// WithDistinctIDKey changes the property key used for the distinct id when creating events
+// Use for custom collectors that expect a different key, e.g. user_id
+func WithDistinctIDKey(key string) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.distinctIDKey = key
+	}
+}
+
+// WithInstanceID stamps id on every event created by the client to tell which process sent it
+// An empty id is replaced by the hostname with a random suffix
+func WithInstanceID(id string) Options {
+	if id == "" {
+		id = newInstanceID()
+	}
+	return func(mixpanel *ApiClient) {
+		mixpanel.instanceID = id
+	}
+}
+
+// WithInstanceIDKey changes the property key used for the instance id, DefaultInstanceIDProperty by default
+func WithInstanceIDKey(key string) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.instanceIDKey = key
+	}
+}
+
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return hostname
+	}
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
+
+// WithTokenKey changes the property key used for the project token when creating events
+func WithTokenKey(key string) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.tokenKey = key
+	}
+}
+
 // NewApiClient create a new mixpanel client
 func NewApiClient(token string, options ...Options) *ApiClient {
 	mp := &ApiClient{
 		client:        http.DefaultClient,
 		apiEndpoint:   usEndpoint,
 		dataEndpoint:  usDataEndpoint,
+		queryEndpoint: usQueryEndpoint,
 		token:         token,
 		debugHttpCall: &debugHttpCalls{},
+		distinctIDKey: propertyDistinctID,
+		tokenKey:      propertyToken,
+		instanceIDKey: DefaultInstanceIDProperty,
+
+		responseBodyLimit: DefaultResponseBodyLimit,
+
+		serviceAccountExpiryWarning: DefaultServiceAccountExpiryWarning,
 	}
 
 	for _, o := range options {
 		o(mp)
 	}
 
+	// only build our own transport if the caller didn't provide a client
+	if len(mp.transportOptions) > 0 && !mp.customHttpClient {
+		mp.client = newTransportClient(mp.transportOptions)
+	}
+
 	return mp
 }
 
+const redacted = "[redacted]"
+
+// DebugConfig reports the effective client configuration for troubleshooting
+// Credentials are redacted so the result is safe to share
+func (m *ApiClient) DebugConfig() map[string]any {
+	config := map[string]any{
+		"version":        version,
+		"api_endpoint":   m.apiEndpoint,
+		"data_endpoint":  m.dataEndpoint,
+		"query_endpoint": m.queryEndpoint,
+		"project_id":     m.projectID,
+		"auth":           m.authMode(),
+
+		"custom_http_client":            m.customHttpClient,
+		"http_client_timeout":           m.client.Timeout,
+		"debug_http_calls":              m.debugHttpCall != nil && m.debugHttpCall.writer != nil,
+		"distinct_id_key":               m.distinctIDKey,
+		"token_key":                     m.tokenKey,
+		"track_compression":             m.trackCompression == Gzip,
+		"event_enrichers":               len(m.eventEnrichers),
+		"event_filters":                 len(m.eventFilters),
+		"dead_letter":                   m.deadLetter != nil,
+		"before_send":                   m.beforeSend != nil,
+		"rate_limit_observer":           m.rateLimitObserver != nil,
+		"response_body_limit":           m.responseBodyLimit,
+		"reject_duplicate_distinct_ids": m.rejectDuplicateDistinctIDs,
+		"time_property_format":          m.timePropertyFormat,
+		"non_finite_float_policy":       m.nonFiniteFloatPolicy,
+	}
+
+	if m.token != "" {
+		config["token"] = redacted
+	}
+	if m.apiSecret != "" {
+		config["api_secret"] = redacted
+	}
+	if m.serviceAccount != nil {
+		config["service_account_username"] = m.serviceAccount.Username
+		config["service_account_secret"] = redacted
+		if !m.serviceAccount.ExpiresAt.IsZero() {
+			config["service_account_expires_at"] = m.serviceAccount.ExpiresAt
+		}
+	}
+	if m.requestSemaphore != nil {
+		config["max_concurrent_requests"] = cap(m.requestSemaphore)
+	}
+	if m.maxPropertyStringLength > 0 {
+		config["max_property_string_length"] = m.maxPropertyStringLength
+		config["reject_long_properties"] = m.rejectLongProperties
+	}
+	if m.instanceID != "" {
+		config["instance_id"] = m.instanceID
+		config["instance_id_key"] = m.instanceIDKey
+	}
+	if len(m.defaultQueryParams) > 0 {
+		// values may carry routing keys, only report which params are set
+		keys := make([]string, 0, len(m.defaultQueryParams))
+		for key := range m.defaultQueryParams {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		config["default_query_params"] = keys
+	}
+	if m.defaultImportOptions != nil {
+		config["default_import_options"] = *m.defaultImportOptions
+	}
+	if m.consentTarget != 0 {
+		config["consent"] = m.consent
+		config["consent_target"] = m.consentTarget
+	}
+	if m.schema != nil {
+		config["schema_validation_mode"] = m.schemaValidationMode
+	}
+	// the transport options are ignored when a custom client is provided
+	if !m.customHttpClient {
+		config["insecure_skip_verify"] = m.insecureSkipVerify
+		if m.connectionPool != nil {
+			config["connection_pool"] = *m.connectionPool
+		}
+	}
+
+	return config
+}
+
+// authMode is the credential used for export, query and import requests
+func (m *ApiClient) authMode() string {
+	switch {
+	case m.serviceAccount != nil:
+		return "service_account"
+	case m.apiSecret != "":
+		return "api_secret"
+	case m.token != "":
+		return "token"
+	default:
+		return "none"
+	}
+}
+
+// ServiceAccountExpiresSoon reports if the service account expires within the duration
+// Always false if the service account has no known expiry
+func (m *ApiClient) ServiceAccountExpiresSoon(within time.Duration) bool {
+	if m.serviceAccount == nil || m.serviceAccount.ExpiresAt.IsZero() {
+		return false
+	}
+
+	return time.Until(m.serviceAccount.ExpiresAt) < within
+}
+
+// warnServiceAccountExpiry logs once if the service account is about to expire
+func (m *ApiClient) warnServiceAccountExpiry() {
+	if m.logger == nil || !m.ServiceAccountExpiresSoon(m.serviceAccountExpiryWarning) {
+		return
+	}
+
+	m.serviceAccountExpiryOnce.Do(func() {
+		m.logger.Printf("mixpanel: service account %s expires at %s", m.serviceAccount.Username, m.serviceAccount.ExpiresAt.Format(time.RFC3339))
+	})
+}
+
 // Event is a mixpanel event: https://help.mixpanel.com/hc/en-us/articles/360041995352-Mixpanel-Concepts-Events
 type Event struct {
 	Name       string         `json:"event"`
 	Properties map[string]any `json:"properties"`
+
+	// tokenKey is the token property when the client set one with WithTokenKey, empty means propertyToken
+	tokenKey string
 }
 
 // NewEvent creates a new mixpanel event to track
 func (m *ApiClient) NewEvent(name string, distinctID string, properties map[string]any) *Event {
+	e := m.NewAnonymousEvent(name, properties)
+	e.Properties[m.distinctIDKey] = distinctID
+
+	return e
+}
+
+// NewAnonymousEvent creates a new mixpanel event without a distinct_id
+// Use for anonymous tracking, set $device_id in properties to tie anonymous events together
+// https://docs.mixpanel.com/docs/tracking-methods/id-management/identifying-users
+func (m *ApiClient) NewAnonymousEvent(name string, properties map[string]any) *Event {
 	e := &Event{
 		Name: name,
 	}
@@ -197,10 +786,15 @@ func (m *ApiClient) NewEvent(name string, distinctID string, properties map[stri
 		properties = make(map[string]any)
 	}
 
-	properties[propertyToken] = m.token
-	properties[propertyDistinctID] = distinctID
+	properties[m.tokenKey] = m.token
+	if m.tokenKey != propertyToken {
+		e.tokenKey = m.tokenKey
+	}
 	properties[propertyMpLib] = goLib
 	properties[propertyLibVersion] = version
+	if m.instanceID != "" {
+		properties[m.instanceIDKey] = m.instanceID
+	}
 	e.Properties = properties
 
 	return e
@@ -223,12 +817,110 @@ func (m *ApiClient) NewEventFromJson(json map[string]any) (*Event, error) {
 	}, nil
 }
 
+// ErrReservedProperty is returned when trying to overwrite a property managed by the sdk
+var ErrReservedProperty = errors.New("property is reserved")
+
+// isReservedProperty reports if key is the event's token property or one of the library properties
+func (e *Event) isReservedProperty(key string) bool {
+	tokenKey := propertyToken
+	if e.tokenKey != "" {
+		tokenKey = e.tokenKey
+	}
+
+	switch key {
+	case tokenKey, propertyMpLib, propertyLibVersion:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetProperty sets a property on the event
+// The token and library properties can't be overwritten, the token property follows WithTokenKey
+// for events created by the client
+func (e *Event) SetProperty(key string, value any) error {
+	if e.isReservedProperty(key) {
+		return fmt.Errorf("%w: %s", ErrReservedProperty, key)
+	}
+
+	if e.Properties == nil {
+		e.Properties = make(map[string]any)
+	}
+	e.Properties[key] = value
+	return nil
+}
+
+// SetProperties merges the properties into the event
+// If any key is reserved no properties are set
+func (e *Event) SetProperties(properties map[string]any) error {
+	for key := range properties {
+		if e.isReservedProperty(key) {
+			return fmt.Errorf("%w: %s", ErrReservedProperty, key)
+		}
+	}
+
+	if e.Properties == nil {
+		e.Properties = make(map[string]any, len(properties))
+	}
+	for key, value := range properties {
+		e.Properties[key] = value
+	}
+	return nil
+}
+
 // AddTime insert the time properties into the event
 // https://developer.mixpanel.com/reference/import-events#propertiestime
 func (e *Event) AddTime(t time.Time) {
 	e.Properties[propertyTime] = t.UnixMilli()
 }
 
+// AddTimeSeconds insert the time properties into the event from a unix timestamp in seconds
+func (e *Event) AddTimeSeconds(sec int64) {
+	e.Properties[propertyTime] = sec * 1000
+}
+
+// maxUnixSeconds is the cutoff used to tell second and millisecond timestamps apart
+// timestamps below it are seconds (before the year 5138), above it milliseconds
+const maxUnixSeconds = 100_000_000_000
+
+// NormalizeTime converts a time property in seconds (e.g. from Export) to milliseconds
+// Times already in milliseconds are left untouched
+func (e *Event) NormalizeTime() {
+	if t, ok := e.timeMillis(); ok {
+		e.Properties[propertyTime] = t
+	}
+}
+
+// timeMillis returns the time property in milliseconds
+func (e *Event) timeMillis() (int64, bool) {
+	var t int64
+	switch v := e.Properties[propertyTime].(type) {
+	case int:
+		t = int64(v)
+	case int64:
+		t = v
+	case float64:
+		t = int64(v)
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			f, err := v.Float64()
+			if err != nil {
+				return 0, false
+			}
+			n = int64(f)
+		}
+		t = n
+	default:
+		return 0, false
+	}
+
+	if t < maxUnixSeconds {
+		t *= 1000
+	}
+	return t, true
+}
+
 // AddInsertID inserts the insert_id property into the properties
 // https://developer.mixpanel.com/reference/import-events#propertiesinsert_id
 func (e *Event) AddInsertID(insertID string) {
@@ -244,3 +936,52 @@ func (e *Event) AddIP(ip net.IP) {
 	}
 	e.Properties[propertyIP] = ip.String()
 }
+
+// utmParams are the query params AddUTMParams copies into event properties
+var utmParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// AddUTMParams sets the utm_source, utm_medium, utm_campaign, utm_term and utm_content properties from a parsed query
+// Params missing from values are left untouched
+func (e *Event) AddUTMParams(values url.Values) {
+	for _, param := range utmParams {
+		if v := values.Get(param); v != "" {
+			e.Properties[param] = v
+		}
+	}
+}
+
+// AddReferrer sets the $referrer and $referring_domain properties
+// The domain is only set if ref is a valid absolute url
+func (e *Event) AddReferrer(ref string) {
+	if ref == "" {
+		return
+	}
+	e.Properties[propertyReferrer] = ref
+
+	if u, err := url.Parse(ref); err == nil && u.Hostname() != "" {
+		e.Properties[propertyReferringDomain] = u.Hostname()
+	}
+}
+
+// insertIDFromProperty sets the insert_id from the value of property if no insert_id is set
+func (e *Event) insertIDFromProperty(property string) {
+	if _, ok := e.Properties[propertyInsertID]; ok {
+		return
+	}
+
+	value, ok := e.Properties[property]
+	if !ok || value == nil {
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		e.AddInsertID(v)
+	case float64:
+		e.AddInsertID(strconv.FormatFloat(v, 'f', -1, 64))
+	case json.Number:
+		e.AddInsertID(v.String())
+	default:
+		e.AddInsertID(fmt.Sprint(v))
+	}
+}
@@ -1,9 +1,16 @@
 package mixpanel
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,6 +19,21 @@ func TestMixpanelOptions(t *testing.T) {
 		mp := NewApiClient("", EuResidency())
 		require.Equal(t, mp.apiEndpoint, euEndpoint)
 		require.Equal(t, mp.dataEndpoint, euDataEndpoint)
+		require.Equal(t, mp.queryEndpoint, euQueryEndpoint)
+	})
+
+	t.Run("eu region", func(t *testing.T) {
+		mp := NewApiClient("", WithRegion(RegionEU))
+		require.Equal(t, euEndpoint, mp.apiEndpoint)
+		require.Equal(t, euDataEndpoint, mp.dataEndpoint)
+		require.Equal(t, euQueryEndpoint, mp.queryEndpoint)
+	})
+
+	t.Run("us region", func(t *testing.T) {
+		mp := NewApiClient("", EuResidency(), WithRegion(RegionUS))
+		require.Equal(t, usEndpoint, mp.apiEndpoint)
+		require.Equal(t, usDataEndpoint, mp.dataEndpoint)
+		require.Equal(t, usQueryEndpoint, mp.queryEndpoint)
 	})
 
 	t.Run("api secret", func(t *testing.T) {
@@ -37,6 +59,11 @@ func TestMixpanelOptions(t *testing.T) {
 		require.Equal(t, "https://localhost:8080", mp.dataEndpoint)
 	})
 
+	t.Run("set query proxy", func(t *testing.T) {
+		mp := NewApiClient("", ProxyQueryLocation("https://localhost:8080"))
+		require.Equal(t, "https://localhost:8080", mp.queryEndpoint)
+	})
+
 	t.Run("debug http", func(t *testing.T) {
 		mp := NewApiClient("", DebugHttpCalls(os.Stdout))
 		require.NotNil(t, mp.debugHttpCall)
@@ -46,4 +73,153 @@ func TestMixpanelOptions(t *testing.T) {
 		mp := NewApiClient("", HttpClient(nil))
 		require.Nil(t, mp.client)
 	})
+
+	t.Run("connection pool", func(t *testing.T) {
+		mp := NewApiClient("", WithConnectionPool(200, 50, 30*time.Second))
+		require.NotEqual(t, http.DefaultClient, mp.client)
+
+		transport, ok := mp.client.Transport.(*http.Transport)
+		require.True(t, ok)
+		require.Equal(t, 200, transport.MaxIdleConns)
+		require.Equal(t, 50, transport.MaxIdleConnsPerHost)
+		require.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		mp := NewApiClient("", WithInsecureSkipVerify(), WithConnectionPool(200, 50, 30*time.Second))
+
+		transport, ok := mp.client.Transport.(*http.Transport)
+		require.True(t, ok)
+		require.NotNil(t, transport.TLSClientConfig)
+		require.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+		require.Equal(t, 200, transport.MaxIdleConns)
+
+		defaultTransport := http.DefaultTransport.(*http.Transport)
+		require.True(t, defaultTransport.TLSClientConfig == nil || !defaultTransport.TLSClientConfig.InsecureSkipVerify)
+	})
+
+	t.Run("insecure skip verify does not change a custom client", func(t *testing.T) {
+		client := &http.Client{}
+		mp := NewApiClient("", HttpClient(client), WithInsecureSkipVerify())
+		require.Same(t, client, mp.client)
+		require.Nil(t, client.Transport)
+	})
+
+	t.Run("connection pool does not replace a custom client", func(t *testing.T) {
+		client := &http.Client{}
+		mp := NewApiClient("", HttpClient(client), WithConnectionPool(200, 50, 30*time.Second))
+		require.Same(t, client, mp.client)
+		require.Nil(t, client.Transport)
+	})
+}
+
+func TestServiceAccountExpiry(t *testing.T) {
+	t.Run("expires soon", func(t *testing.T) {
+		mp := NewApiClient("", ServiceAccountWithExpiry(117, "username", "secret", time.Now().Add(time.Hour)))
+		require.Equal(t, 117, mp.projectID)
+		require.Equal(t, "username", mp.serviceAccount.Username)
+		require.True(t, mp.ServiceAccountExpiresSoon(24*time.Hour))
+		require.False(t, mp.ServiceAccountExpiresSoon(time.Minute))
+	})
+
+	t.Run("no expiry", func(t *testing.T) {
+		mp := NewApiClient("", ServiceAccount(117, "username", "secret"))
+		require.False(t, mp.ServiceAccountExpiresSoon(24*time.Hour))
+	})
+
+	t.Run("warns once near expiry", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), httpmock.NewStringResponder(http.StatusOK, ""))
+
+		var buf bytes.Buffer
+		mp := NewApiClient("",
+			ServiceAccountWithExpiry(117, "username", "secret", time.Now().Add(time.Hour)),
+			WithLogger(log.New(&buf, "", 0)),
+		)
+
+		for i := 0; i < 2; i++ {
+			_, err := mp.Export(context.Background(), time.Now(), time.Now(), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("service account username expires")))
+	})
+
+	t.Run("no warning outside the window", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usDataEndpoint, exportUrl), httpmock.NewStringResponder(http.StatusOK, ""))
+
+		var buf bytes.Buffer
+		mp := NewApiClient("",
+			ServiceAccountWithExpiry(117, "username", "secret", time.Now().Add(time.Hour)),
+			WithServiceAccountExpiryWarning(time.Minute),
+			WithLogger(log.New(&buf, "", 0)),
+		)
+
+		_, err := mp.Export(context.Background(), time.Now(), time.Now(), ExportNoLimit, ExportNoEventFilter, ExportNoWhereFilter)
+		require.NoError(t, err)
+		require.Empty(t, buf.String())
+	})
+}
+
+func TestDebugConfig(t *testing.T) {
+	t.Run("redacts credentials", func(t *testing.T) {
+		mp := NewApiClient("some-token",
+			ServiceAccount(117, "username", "service-secret"),
+			ApiSecret("api-secret"),
+			EuResidency(),
+			WithMaxConcurrentRequests(4),
+		)
+		config := mp.DebugConfig()
+
+		require.Equal(t, euEndpoint, config["api_endpoint"])
+		require.Equal(t, euDataEndpoint, config["data_endpoint"])
+		require.Equal(t, euQueryEndpoint, config["query_endpoint"])
+		require.Equal(t, 117, config["project_id"])
+		require.Equal(t, "service_account", config["auth"])
+		require.Equal(t, "username", config["service_account_username"])
+		require.Equal(t, 4, config["max_concurrent_requests"])
+
+		dump := fmt.Sprint(config)
+		require.NotContains(t, dump, "some-token")
+		require.NotContains(t, dump, "service-secret")
+		require.NotContains(t, dump, "api-secret")
+	})
+
+	t.Run("reports the auth mode", func(t *testing.T) {
+		require.Equal(t, "api_secret", NewApiClient("token", ApiSecret("api-secret")).DebugConfig()["auth"])
+		require.Equal(t, "token", NewApiClient("token").DebugConfig()["auth"])
+		require.Equal(t, "none", NewApiClient("").DebugConfig()["auth"])
+	})
+
+	t.Run("reports the active options", func(t *testing.T) {
+		mp := NewApiClient("token",
+			WithInsecureSkipVerify(),
+			WithConnectionPool(10, 2, time.Minute),
+			WithBeforeSend(func(ctx context.Context, endpoint string, events []*Event) error { return nil }),
+			WithSchemaValidation(&Schema{}, ValidationReject),
+			WithNonFiniteFloatPolicy(FloatPolicyDrop),
+		)
+		config := mp.DebugConfig()
+
+		require.Equal(t, false, config["custom_http_client"])
+		require.Equal(t, true, config["insecure_skip_verify"])
+		require.Equal(t, connectionPool{MaxIdleConns: 10, MaxIdleConnsPerHost: 2, IdleConnTimeout: time.Minute}, config["connection_pool"])
+		require.Equal(t, true, config["before_send"])
+		require.Equal(t, ValidationReject, config["schema_validation_mode"])
+		require.Equal(t, FloatPolicyDrop, config["non_finite_float_policy"])
+	})
+
+	t.Run("transport options are not reported with a custom client", func(t *testing.T) {
+		mp := NewApiClient("token",
+			HttpClient(&http.Client{Timeout: 5 * time.Second}),
+			WithInsecureSkipVerify(),
+		)
+		config := mp.DebugConfig()
+
+		require.Equal(t, true, config["custom_http_client"])
+		require.Equal(t, 5*time.Second, config["http_client_timeout"])
+		require.NotContains(t, config, "insecure_skip_verify")
+	})
 }
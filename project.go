@@ -0,0 +1,76 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	projectUrl = "/api/app/projects/%d"
+)
+
+var (
+	ErrServiceAccountRequired = errors.New("a service account is required")
+)
+
+// ProjectSettings is the project level configuration
+type ProjectSettings struct {
+	ID   int
+	Name string
+	// Timezone is the project's timezone, used for day boundaries in reporting queries
+	Timezone *time.Location
+}
+
+type projectSettingsResponse struct {
+	Status  string `json:"status"`
+	Results struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Timezone string `json:"timezone"`
+	} `json:"results"`
+}
+
+// ProjectSettings fetches the configuration of the service account's project
+// Need to provide a service account to the client
+func (a *ApiClient) ProjectSettings(ctx context.Context) (*ProjectSettings, error) {
+	if a.serviceAccount == nil {
+		return nil, ErrServiceAccountRequired
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodGet,
+		a.queryEndpoint+fmt.Sprintf(projectUrl, a.projectID),
+		nil,
+		a.serviceAccountAuth(), acceptJson(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project settings: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+		var r projectSettingsResponse
+		if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to json decode response body: %w", err)
+		}
+
+		location, err := time.LoadLocation(r.Results.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse project timezone %q: %w", r.Results.Timezone, err)
+		}
+
+		return &ProjectSettings{
+			ID:       r.Results.ID,
+			Name:     r.Results.Name,
+			Timezone: location,
+		}, nil
+	default:
+		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+}
@@ -0,0 +1,52 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectSettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("can read the project timezone", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s"+projectUrl, usQueryEndpoint, 117), func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`
+				{
+					"status": "ok",
+					"results": {
+						"id": 117,
+						"name": "some project",
+						"timezone": "America/Los_Angeles"
+					}
+				}
+				`)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		settings, err := mp.ProjectSettings(ctx)
+		require.NoError(t, err)
+
+		require.Equal(t, 117, settings.ID)
+		require.Equal(t, "some project", settings.Name)
+		require.Equal(t, "America/Los_Angeles", settings.Timezone.String())
+	})
+
+	t.Run("requires a service account", func(t *testing.T) {
+		mp := NewApiClient("token")
+		_, err := mp.ProjectSettings(ctx)
+		require.ErrorIs(t, err, ErrServiceAccountRequired)
+	})
+}
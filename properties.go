@@ -0,0 +1,193 @@
+package mixpanel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPropertyStringLength is the longest string property value mixpanel accepts
+	// https://docs.mixpanel.com/docs/data-structure/property-reference#supported-data-types
+	MaxPropertyStringLength = 255
+
+	truncationMarker = "..."
+)
+
+// TimeFormat is how time.Time property values are sent to mixpanel
+type TimeFormat int
+
+const (
+	// TimeFormatDefault leaves time.Time values to encoding/json which sends RFC3339 strings
+	TimeFormatDefault TimeFormat = iota
+	// TimeFormatEpochMillis sends time.Time values as unix milliseconds
+	TimeFormatEpochMillis
+	// TimeFormatDateString sends time.Time values as UTC "2006-01-02T15:04:05" date strings
+	TimeFormatDateString
+)
+
+// PropertyLimitError is returned when property values are over the configured limit
+type PropertyLimitError struct {
+	MaxStringLength int
+	// Properties are the offending properties as event_index.property_name
+	Properties []string
+}
+
+func (e PropertyLimitError) Error() string {
+	return fmt.Sprintf("property values longer than %d bytes: %s", e.MaxStringLength, strings.Join(e.Properties, ", "))
+}
+
+// limitPropertyValues truncates or rejects top level string property values over the limit
+// The distinct id, insert id and token properties are left as is, changing them would change the identity of the event
+func (m *ApiClient) limitPropertyValues(events []*Event) error {
+	if m.maxPropertyStringLength <= 0 {
+		return nil
+	}
+
+	var offending []string
+	for i, e := range events {
+		for key, value := range e.Properties {
+			str, ok := value.(string)
+			if !ok || len(str) <= m.maxPropertyStringLength || m.isIdentityProperty(e, key) {
+				continue
+			}
+
+			if m.rejectLongProperties {
+				offending = append(offending, fmt.Sprintf("%d.%s", i, key))
+				continue
+			}
+			e.Properties[key] = truncateString(str, m.maxPropertyStringLength)
+		}
+	}
+
+	if len(offending) > 0 {
+		sort.Strings(offending)
+		return PropertyLimitError{
+			MaxStringLength: m.maxPropertyStringLength,
+			Properties:      offending,
+		}
+	}
+	return nil
+}
+
+func (m *ApiClient) isIdentityProperty(e *Event, key string) bool {
+	tokenKey := m.tokenKey
+	if e.tokenKey != "" {
+		tokenKey = e.tokenKey
+	}
+
+	switch key {
+	case m.distinctIDKey, propertyInsertID, tokenKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// truncateString cuts s to at most maxLen bytes including the marker without splitting a rune
+// The marker is left out when maxLen has no room for it
+func truncateString(s string, maxLen int) string {
+	marker := truncationMarker
+	if maxLen <= len(truncationMarker) {
+		marker = ""
+	}
+
+	cut := maxLen - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
+
+// formatTimeProperties converts top level time.Time property values to the configured format
+func (m *ApiClient) formatTimeProperties(properties map[string]any) {
+	if m.timePropertyFormat == TimeFormatDefault {
+		return
+	}
+
+	for key, value := range properties {
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case *time.Time:
+			if v == nil {
+				continue
+			}
+			t = *v
+		default:
+			continue
+		}
+
+		switch m.timePropertyFormat {
+		case TimeFormatEpochMillis:
+			properties[key] = t.UnixMilli()
+		case TimeFormatDateString:
+			properties[key] = t.UTC().Format(peopleDateFormat)
+		}
+	}
+}
+
+// NonFiniteFloatPolicy is what happens to NaN and infinite property values, which json can't encode
+type NonFiniteFloatPolicy int
+
+const (
+	// FloatPolicyReject fails the call with a NonFiniteFloatError naming the properties
+	FloatPolicyReject NonFiniteFloatPolicy = iota
+	// FloatPolicyDrop removes the properties
+	FloatPolicyDrop
+	// FloatPolicyNull sends the properties as null
+	FloatPolicyNull
+)
+
+// NonFiniteFloatError is returned when property values are NaN or infinite
+type NonFiniteFloatError struct {
+	// Properties are the offending properties as event_index.property_name
+	Properties []string
+}
+
+func (e NonFiniteFloatError) Error() string {
+	return fmt.Sprintf("property values are NaN or infinite: %s", strings.Join(e.Properties, ", "))
+}
+
+// handleNonFiniteFloats applies the client's NonFiniteFloatPolicy to top level property values
+func (m *ApiClient) handleNonFiniteFloats(events []*Event) error {
+	var offending []string
+	for i, e := range events {
+		for key, value := range e.Properties {
+			if isFiniteFloat(value) {
+				continue
+			}
+
+			switch m.nonFiniteFloatPolicy {
+			case FloatPolicyDrop:
+				delete(e.Properties, key)
+			case FloatPolicyNull:
+				e.Properties[key] = nil
+			default:
+				offending = append(offending, fmt.Sprintf("%d.%s", i, key))
+			}
+		}
+	}
+
+	if len(offending) > 0 {
+		sort.Strings(offending)
+		return NonFiniteFloatError{Properties: offending}
+	}
+	return nil
+}
+
+// isFiniteFloat is true for every value that isn't a NaN or infinite float
+func isFiniteFloat(value any) bool {
+	switch v := value.(type) {
+	case float64:
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	case float32:
+		return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+	default:
+		return true
+	}
+}
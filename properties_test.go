@@ -0,0 +1,178 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateString(t *testing.T) {
+	require.Equal(t, "abcdefg...", truncateString(strings.Repeat("abcdefghij", 3), 10))
+	require.Equal(t, "ab", truncateString("abcdef", 2))
+
+	// "é" is 2 bytes, a cut inside of it moves back to the rune start
+	require.Equal(t, "ééé...", truncateString(strings.Repeat("é", 10), 10))
+	require.Equal(t, "é", truncateString(strings.Repeat("é", 10), 3))
+	require.Equal(t, "", truncateString("日本語", 2))
+}
+
+func TestPropertyLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("truncates long values", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token", WithPropertyTruncation(MaxPropertyStringLength))
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{
+			"long":  strings.Repeat("a", 300),
+			"short": "short",
+		})
+
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.Len(t, event.Properties["long"], MaxPropertyStringLength)
+		require.True(t, strings.HasSuffix(event.Properties["long"].(string), truncationMarker))
+		require.Equal(t, "short", event.Properties["short"])
+	})
+
+	t.Run("identity properties are not truncated", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		long := strings.Repeat("a", 20)
+		mp := NewApiClient(long, WithPropertyValidation(10))
+		event := mp.NewEvent("some event", long, map[string]any{propertyInsertID: long})
+
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.Equal(t, long, event.Properties[propertyDistinctID])
+		require.Equal(t, long, event.Properties[propertyInsertID])
+		require.Equal(t, long, event.Properties[propertyToken])
+	})
+
+	t.Run("rejects long values in validation mode", func(t *testing.T) {
+		mp := NewApiClient("token", WithPropertyValidation(MaxPropertyStringLength))
+		events := []*Event{
+			mp.NewEvent("some event", EmptyDistinctID, map[string]any{"ok": "short"}),
+			mp.NewEvent("some event", EmptyDistinctID, map[string]any{
+				"long_1": strings.Repeat("a", 300),
+				"long_2": strings.Repeat("b", 256),
+			}),
+		}
+
+		_, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		limitErr := &PropertyLimitError{}
+		require.ErrorAs(t, err, limitErr)
+		require.Equal(t, []string{"1.long_1", "1.long_2"}, limitErr.Properties)
+		require.Len(t, events[1].Properties["long_1"], 300)
+	})
+}
+
+func TestTimePropertyFormat(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2023, 5, 24, 18, 5, 35, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	t.Run("default leaves time values", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token")
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{"created": created})
+
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.Equal(t, created, event.Properties["created"])
+	})
+
+	t.Run("epoch millis on events", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			require.Equal(t, float64(created.UnixMilli()), events[0].Properties["created"])
+			require.Equal(t, float64(created.UnixMilli()), events[0].Properties["created_ptr"])
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		mp := NewApiClient("token", WithTimePropertyFormat(TimeFormatEpochMillis))
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{
+			"created":     created,
+			"created_ptr": &created,
+		})
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+	})
+
+	t.Run("date string on profiles", func(t *testing.T) {
+		mp := NewApiClient("token", WithTimePropertyFormat(TimeFormatDateString))
+		setupPeopleAndGroupsEndpoint(t, mp, peopleSetURL, func(body io.Reader) {
+			var payloads []peopleSetPayload
+			require.NoError(t, json.NewDecoder(body).Decode(&payloads))
+			require.Equal(t, "2023-05-24T16:05:35", payloads[0].Set["created"])
+		}, peopleAndGroupSuccess())
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{
+			NewPeopleProperties("some-id", map[string]any{"created": created}),
+		}))
+	})
+}
+
+func TestNonFiniteFloats(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rejects naming the property", func(t *testing.T) {
+		mp := NewApiClient("token")
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{
+			"ratio": math.NaN(),
+			"ok":    1.5,
+		})
+
+		err := mp.Track(ctx, []*Event{event})
+		var floatErr NonFiniteFloatError
+		require.ErrorAs(t, err, &floatErr)
+		require.Equal(t, []string{"0.ratio"}, floatErr.Properties)
+		require.Contains(t, err.Error(), "0.ratio")
+	})
+
+	t.Run("drops the property", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token", WithNonFiniteFloatPolicy(FloatPolicyDrop))
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{
+			"ratio": math.Inf(1),
+			"ok":    1.5,
+		})
+
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.NotContains(t, event.Properties, "ratio")
+		require.Equal(t, 1.5, event.Properties["ok"])
+	})
+
+	t.Run("sends null", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			require.Contains(t, events[0].Properties, "ratio")
+			require.Nil(t, events[0].Properties["ratio"])
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		mp := NewApiClient("token", WithNonFiniteFloatPolicy(FloatPolicyNull))
+		event := mp.NewEvent("some event", EmptyDistinctID, map[string]any{"ratio": float32(math.NaN())})
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+	})
+}
@@ -0,0 +1,64 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	topEventsUrl = "/api/2.0/events/top"
+
+	TopEventsGeneral = "general"
+	TopEventsUnique  = "unique"
+	TopEventsAverage = "average"
+)
+
+// TopEvent is today's count for an event compared to yesterday
+type TopEvent struct {
+	Event         string  `json:"event"`
+	Amount        int     `json:"amount"`
+	PercentChange float64 `json:"percent_change"`
+}
+
+type TopEventsResult struct {
+	Type   string     `json:"type"`
+	Events []TopEvent `json:"events"`
+}
+
+// TopEvents calls the Today's Top Events API
+// https://developer.mixpanel.com/reference/list-top-events
+// eventType is one of TopEventsGeneral, TopEventsUnique or TopEventsAverage
+func (a *ApiClient) TopEvents(ctx context.Context, eventType string, limit int) (*TopEventsResult, error) {
+	query := url.Values{}
+	query.Add("type", eventType)
+	if limit > 0 {
+		query.Add("limit", strconv.Itoa(limit))
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodGet,
+		a.queryEndpoint+topEventsUrl,
+		nil,
+		a.exportServiceAccount(), acceptJson(), addQueryParams(query),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top events: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+		var r TopEventsResult
+		if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to json decode response body: %w", err)
+		}
+		return &r, nil
+	default:
+		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+}
@@ -0,0 +1,66 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopEvents(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("can get top events", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		queryParams := url.Values{}
+		queryParams.Add("type", TopEventsGeneral)
+		queryParams.Add("limit", "2")
+		queryParams.Add("project_id", "117")
+
+		httpmock.RegisterResponderWithQuery(http.MethodGet, fmt.Sprintf("%s%s", usQueryEndpoint, topEventsUrl), queryParams, func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`
+				{
+					"events": [
+						{"amount": 2, "event": "funnel", "percent_change": -0.35},
+						{"amount": 75, "event": "pages", "percent_change": -0.2}
+					],
+					"type": "general"
+				}
+				`)),
+			}, nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		result, err := mp.TopEvents(ctx, TopEventsGeneral, 2)
+		require.NoError(t, err)
+
+		require.Equal(t, TopEventsGeneral, result.Type)
+		require.Equal(t, []TopEvent{
+			{Event: "funnel", Amount: 2, PercentChange: -0.35},
+			{Event: "pages", Amount: 75, PercentChange: -0.2},
+		}, result.Events)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s%s", usQueryEndpoint, topEventsUrl), httpmock.NewStringResponder(http.StatusUnauthorized, "unauthorized"))
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		_, err := mp.TopEvents(ctx, TopEventsGeneral, 2)
+		httpErr := &HttpError{}
+		require.ErrorAs(t, err, httpErr)
+		require.Equal(t, http.StatusUnauthorized, httpErr.Status)
+	})
+}
@@ -0,0 +1,223 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	schemasUrl = "/api/app/projects/%d/schemas"
+
+	schemaEntityEvent   = "event"
+	schemaEntityProfile = "profile"
+)
+
+// Schema is the project's Lexicon, the events and profile properties it defines
+type Schema struct {
+	Events   []SchemaEntity
+	Profiles []SchemaEntity
+}
+
+// SchemaEntity is the definition of an event or profile and its properties
+type SchemaEntity struct {
+	Name        string
+	Description string
+	// Properties are keyed by property name
+	Properties map[string]SchemaProperty
+}
+
+// SchemaProperty is the declared type of a property
+type SchemaProperty struct {
+	// Type is the json schema type, e.g. string, number, boolean, array or object
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Event returns the definition of the event called name
+func (s *Schema) Event(name string) (SchemaEntity, bool) {
+	for _, e := range s.Events {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return SchemaEntity{}, false
+}
+
+type schemasResponse struct {
+	Status  string `json:"status"`
+	Results []struct {
+		EntityType string `json:"entityType"`
+		Name       string `json:"name"`
+		SchemaJson struct {
+			Description string                    `json:"description"`
+			Properties  map[string]SchemaProperty `json:"properties"`
+		} `json:"schemaJson"`
+	} `json:"results"`
+}
+
+// GetSchema calls the Lexicon Schemas API for the service account's project
+// https://developer.mixpanel.com/reference/list-all-schemas-for-project
+// Need to provide a service account to the client
+func (a *ApiClient) GetSchema(ctx context.Context) (*Schema, error) {
+	if a.serviceAccount == nil {
+		return nil, ErrServiceAccountRequired
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodGet,
+		a.queryEndpoint+fmt.Sprintf(schemasUrl, a.projectID),
+		nil,
+		a.serviceAccountAuth(), acceptJson(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	switch httpResponse.StatusCode {
+	case http.StatusOK:
+		var r schemasResponse
+		if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to json decode response body: %w", err)
+		}
+
+		schema := &Schema{}
+		for _, result := range r.Results {
+			entity := SchemaEntity{
+				Name:        result.Name,
+				Description: result.SchemaJson.Description,
+				Properties:  result.SchemaJson.Properties,
+			}
+			switch result.EntityType {
+			case schemaEntityEvent:
+				schema.Events = append(schema.Events, entity)
+			case schemaEntityProfile:
+				schema.Profiles = append(schema.Profiles, entity)
+			}
+		}
+		return schema, nil
+	default:
+		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body, a.responseBodyLimit)
+	}
+}
+
+// ValidationMode is what WithSchemaValidation does with events that don't match the schema
+type ValidationMode int
+
+const (
+	// ValidationWarn reports the violations to the client's logger and sends the events
+	ValidationWarn ValidationMode = iota
+	// ValidationReject fails the call with a SchemaValidationError
+	ValidationReject
+)
+
+// SchemaValidationError is returned when events don't match the schema with ValidationReject
+type SchemaValidationError struct {
+	// Violations are formatted as event_index.property_name: reason
+	Violations []string
+}
+
+func (e SchemaValidationError) Error() string {
+	return fmt.Sprintf("events do not match the schema: %s", strings.Join(e.Violations, "; "))
+}
+
+// validateSchema checks the properties of events declared in the schema
+// Events missing from the schema and mixpanel/sdk properties are not checked
+func (m *ApiClient) validateSchema(events []*Event) error {
+	if m.schema == nil {
+		return nil
+	}
+
+	var violations []string
+	for i, e := range events {
+		declared, ok := m.schema.Event(e.Name)
+		if !ok {
+			continue
+		}
+
+		for key, value := range e.Properties {
+			if m.isSdkProperty(key) {
+				continue
+			}
+
+			property, ok := declared.Properties[key]
+			if !ok {
+				violations = append(violations, fmt.Sprintf("%d.%s: undeclared property", i, key))
+				continue
+			}
+			if actual := schemaType(value); !schemaTypeMatches(property.Type, actual) {
+				violations = append(violations, fmt.Sprintf("%d.%s: expected %s, got %s", i, key, property.Type, actual))
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Strings(violations)
+
+	if m.schemaValidationMode == ValidationReject {
+		return SchemaValidationError{Violations: violations}
+	}
+	if m.logger != nil {
+		for _, v := range violations {
+			m.logger.Printf("mixpanel: schema violation %s", v)
+		}
+	}
+	return nil
+}
+
+// isSdkProperty is true for properties added by mixpanel or this sdk rather than the caller
+func (m *ApiClient) isSdkProperty(key string) bool {
+	switch key {
+	case m.tokenKey, m.distinctIDKey, m.instanceIDKey, propertyTime, propertyIP, propertyMpLib:
+		return true
+	}
+	return strings.HasPrefix(key, "$") || strings.HasPrefix(key, "mp_")
+}
+
+// schemaType is the json schema type of value
+func schemaType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string, time.Time:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func schemaTypeMatches(declared, actual string) bool {
+	switch declared {
+	case "", actual:
+		return true
+	case "integer":
+		return actual == "number"
+	default:
+		// values json encodes in a way we can't tell are not flagged
+		return actual == "unknown"
+	}
+}
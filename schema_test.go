@@ -0,0 +1,141 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("parses events and profiles", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf("%s"+schemasUrl, usQueryEndpoint, 117), func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("username:secret")), req.Header.Get("authorization"))
+
+			return httpmock.NewStringResponse(http.StatusOK, `
+			{
+				"status": "ok",
+				"results": [
+					{
+						"entityType": "event",
+						"name": "Added To Cart",
+						"schemaJson": {
+							"description": "user added an item",
+							"properties": {
+								"item": {"type": "string", "description": "item name"},
+								"price": {"type": "number"}
+							}
+						}
+					},
+					{
+						"entityType": "profile",
+						"name": "$user",
+						"schemaJson": {
+							"properties": {
+								"$email": {"type": "string"}
+							}
+						}
+					}
+				]
+			}
+			`), nil
+		})
+
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		schema, err := mp.GetSchema(ctx)
+		require.NoError(t, err)
+
+		event, ok := schema.Event("Added To Cart")
+		require.True(t, ok)
+		require.Equal(t, "user added an item", event.Description)
+		require.Equal(t, map[string]SchemaProperty{
+			"item":  {Type: "string", Description: "item name"},
+			"price": {Type: "number"},
+		}, event.Properties)
+
+		require.Len(t, schema.Profiles, 1)
+		require.Equal(t, "string", schema.Profiles[0].Properties["$email"].Type)
+
+		_, ok = schema.Event("unknown")
+		require.False(t, ok)
+	})
+
+	t.Run("requires a service account", func(t *testing.T) {
+		mp := NewApiClient("token")
+		_, err := mp.GetSchema(ctx)
+		require.ErrorIs(t, err, ErrServiceAccountRequired)
+	})
+}
+
+func TestSchemaValidation(t *testing.T) {
+	ctx := context.Background()
+	schema := &Schema{
+		Events: []SchemaEntity{
+			{
+				Name: "Added To Cart",
+				Properties: map[string]SchemaProperty{
+					"item":  {Type: "string"},
+					"price": {Type: "number"},
+				},
+			},
+		},
+	}
+
+	t.Run("rejects type mismatches and undeclared properties", func(t *testing.T) {
+		mp := NewApiClient("token", WithSchemaValidation(schema, ValidationReject))
+		event := mp.NewEvent("Added To Cart", "some-id", map[string]any{
+			"item":  "hat",
+			"price": "12.50",
+			"color": "red",
+		})
+
+		err := mp.Track(ctx, []*Event{event})
+		var schemaErr SchemaValidationError
+		require.ErrorAs(t, err, &schemaErr)
+		require.Equal(t, []string{
+			"0.color: undeclared property",
+			"0.price: expected number, got string",
+		}, schemaErr.Violations)
+	})
+
+	t.Run("warns and sends", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		var buf bytes.Buffer
+		mp := NewApiClient("token", WithSchemaValidation(schema, ValidationWarn), WithLogger(log.New(&buf, "", 0)))
+		event := mp.NewEvent("Added To Cart", "some-id", map[string]any{
+			"item":  "hat",
+			"price": "12.50",
+		})
+
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+		require.Contains(t, buf.String(), "0.price: expected number, got string")
+	})
+
+	t.Run("valid and undeclared events pass", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", usEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		mp := NewApiClient("token", WithSchemaValidation(schema, ValidationReject))
+		events := []*Event{
+			mp.NewEvent("Added To Cart", "some-id", map[string]any{"item": "hat", "price": 12.5, "$device_id": "some-device"}),
+			mp.NewEvent("Other Event", "some-id", map[string]any{"anything": true}),
+		}
+		require.NoError(t, mp.Track(ctx, events))
+	})
+}
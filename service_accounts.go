@@ -0,0 +1,153 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	serviceAccountsUrl = "/api/app/organizations/%d/service-accounts"
+)
+
+// ServiceAccountPermissionError is returned when the credentials are not allowed to manage service accounts
+type ServiceAccountPermissionError struct {
+	HttpError
+}
+
+func (e ServiceAccountPermissionError) Error() string {
+	return fmt.Sprintf("not permitted to manage service accounts: %s", e.HttpError.Error())
+}
+
+func (e ServiceAccountPermissionError) Unwrap() error {
+	return e.HttpError
+}
+
+// ServiceAccountInfo is a service account in an organization
+type ServiceAccountInfo struct {
+	ID       int        `json:"id"`
+	Username string     `json:"username"`
+	Role     string     `json:"role"`
+	Expires  *time.Time `json:"expires"`
+	// Secret is only returned when the service account is created
+	Secret string `json:"token,omitempty"`
+}
+
+type ServiceAccountProject struct {
+	ID   int    `json:"id"`
+	Role string `json:"role"`
+}
+
+type CreateServiceAccountRequest struct {
+	OrganizationID int                     `json:"-"`
+	Username       string                  `json:"username"`
+	Role           string                  `json:"role"`
+	Expires        *time.Time              `json:"expires,omitempty"`
+	Projects       []ServiceAccountProject `json:"projects,omitempty"`
+}
+
+type serviceAccountsListResponse struct {
+	Status  string                `json:"status"`
+	Results []*ServiceAccountInfo `json:"results"`
+}
+
+type serviceAccountsCreateResponse struct {
+	Status  string              `json:"status"`
+	Results *ServiceAccountInfo `json:"results"`
+}
+
+// ListServiceAccounts calls the List Service Accounts API
+// https://developer.mixpanel.com/reference/list-service-accounts-for-organization
+// Need to provide an organization admin service account to the client
+func (a *ApiClient) ListServiceAccounts(ctx context.Context, orgID int) ([]ServiceAccountInfo, error) {
+	if a.serviceAccount == nil {
+		return nil, ErrServiceAccountRequired
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodGet,
+		a.queryEndpoint+fmt.Sprintf(serviceAccountsUrl, orgID),
+		nil,
+		a.serviceAccountAuth(), acceptJson(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	if err := processServiceAccountsResponse(httpResponse, a.responseBodyLimit); err != nil {
+		return nil, err
+	}
+
+	var r serviceAccountsListResponse
+	if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to json decode response body: %w", err)
+	}
+
+	accounts := make([]ServiceAccountInfo, 0, len(r.Results))
+	for _, account := range r.Results {
+		if account == nil {
+			continue
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, nil
+}
+
+// CreateServiceAccount calls the Create Service Account API
+// https://developer.mixpanel.com/reference/create-service-account
+// Need to provide an organization admin service account to the client
+func (a *ApiClient) CreateServiceAccount(ctx context.Context, req CreateServiceAccountRequest) (*ServiceAccountInfo, error) {
+	if a.serviceAccount == nil {
+		return nil, ErrServiceAccountRequired
+	}
+
+	body, err := makeRequestBody(req, jsonPayload, None)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	httpResponse, err := a.doRequestBody(
+		ctx,
+		http.MethodPost,
+		a.queryEndpoint+fmt.Sprintf(serviceAccountsUrl, req.OrganizationID),
+		body,
+		a.serviceAccountAuth(), acceptJson(), applicationJsonHeader(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service account: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	if err := processServiceAccountsResponse(httpResponse, a.responseBodyLimit); err != nil {
+		return nil, err
+	}
+
+	var r serviceAccountsCreateResponse
+	if err := json.NewDecoder(httpResponse.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to json decode response body: %w", err)
+	}
+	if r.Results == nil {
+		return nil, fmt.Errorf("service account missing from response")
+	}
+
+	return r.Results, nil
+}
+
+func processServiceAccountsResponse(response *http.Response, limit int64) error {
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		err := newHttpError(response.StatusCode, response.Body, limit)
+		if httpErr, ok := err.(HttpError); ok {
+			return ServiceAccountPermissionError{HttpError: httpErr}
+		}
+		return err
+	default:
+		return newHttpError(response.StatusCode, response.Body, limit)
+	}
+}
@@ -0,0 +1,135 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServiceAccountsEndpoint(t *testing.T, client *ApiClient, method string, orgID int, testReq func(req *http.Request), httpResponse *http.Response) {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	httpmock.RegisterResponder(method, fmt.Sprintf("%s"+serviceAccountsUrl, client.queryEndpoint, orgID), func(req *http.Request) (*http.Response, error) {
+		require.Equal(t, "application/json", req.Header.Get("accept"))
+		auth := req.Header.Get("authorization")
+		require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte(client.serviceAccount.Username+":"+client.serviceAccount.Secret)), auth)
+
+		testReq(req)
+
+		return httpResponse, nil
+	})
+}
+
+func TestListServiceAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("can list service accounts", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupServiceAccountsEndpoint(t, mp, http.MethodGet, 42, func(req *http.Request) {}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`
+			{
+				"status": "ok",
+				"results": [
+					{"id": 1, "username": "sa-1.mp-service-account", "role": "admin", "expires": "2024-01-01T00:00:00Z"},
+					{"id": 2, "username": "sa-2.mp-service-account", "role": "consumer", "expires": null}
+				]
+			}
+			`)),
+		})
+
+		accounts, err := mp.ListServiceAccounts(ctx, 42)
+		require.NoError(t, err)
+		require.Len(t, accounts, 2)
+
+		require.Equal(t, 1, accounts[0].ID)
+		require.Equal(t, "sa-1.mp-service-account", accounts[0].Username)
+		require.Equal(t, "admin", accounts[0].Role)
+		require.NotNil(t, accounts[0].Expires)
+		require.True(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Equal(*accounts[0].Expires))
+		require.Nil(t, accounts[1].Expires)
+	})
+
+	t.Run("skips null results", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupServiceAccountsEndpoint(t, mp, http.MethodGet, 42, func(req *http.Request) {}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"status": "ok", "results": [null, {"id": 1, "username": "sa-1.mp-service-account", "role": "admin"}]}`)),
+		})
+
+		accounts, err := mp.ListServiceAccounts(ctx, 42)
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		require.Equal(t, 1, accounts[0].ID)
+	})
+
+	t.Run("requires a service account", func(t *testing.T) {
+		mp := NewApiClient("token")
+		_, err := mp.ListServiceAccounts(ctx, 42)
+		require.ErrorIs(t, err, ErrServiceAccountRequired)
+	})
+
+	t.Run("permission error", func(t *testing.T) {
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupServiceAccountsEndpoint(t, mp, http.MethodGet, 42, func(req *http.Request) {}, &http.Response{
+			StatusCode: http.StatusForbidden,
+			Body:       io.NopCloser(strings.NewReader(`{"status": "error", "error": "forbidden"}`)),
+		})
+
+		_, err := mp.ListServiceAccounts(ctx, 42)
+		permissionErr := &ServiceAccountPermissionError{}
+		require.ErrorAs(t, err, permissionErr)
+		require.Equal(t, http.StatusForbidden, permissionErr.Status)
+		require.ErrorIs(t, err, ErrUnexpectedStatus)
+	})
+}
+
+func TestCreateServiceAccount(t *testing.T) {
+	ctx := context.Background()
+
+	mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+	setupServiceAccountsEndpoint(t, mp, http.MethodPost, 42, func(req *http.Request) {
+		require.Equal(t, "application/json", req.Header.Get("content-type"))
+
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+		require.Equal(t, "new-account", payload["username"])
+		require.Equal(t, "admin", payload["role"])
+		require.Len(t, payload["projects"], 1)
+	}, &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(`
+		{
+			"status": "ok",
+			"results": {"id": 3, "username": "new-account.mp-service-account", "role": "admin", "token": "new-secret"}
+		}
+		`)),
+	})
+
+	account, err := mp.CreateServiceAccount(ctx, CreateServiceAccountRequest{
+		OrganizationID: 42,
+		Username:       "new-account",
+		Role:           "admin",
+		Projects:       []ServiceAccountProject{{ID: 117, Role: "admin"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, account.ID)
+	require.Equal(t, "new-account.mp-service-account", account.Username)
+	require.Equal(t, "new-secret", account.Secret)
+}
+
+func TestCreateServiceAccountRequiresServiceAccount(t *testing.T) {
+	mp := NewApiClient("token")
+	_, err := mp.CreateServiceAccount(context.Background(), CreateServiceAccountRequest{OrganizationID: 42})
+	require.ErrorIs(t, err, ErrServiceAccountRequired)
+}
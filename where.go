@@ -0,0 +1,91 @@
+package mixpanel
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// WhereBuilder builds a where expression for Export and the Engage Query API
+// Property names and values are escaped so they can't change the expression
+// https://developer.mixpanel.com/reference/segmentation-expressions
+type WhereBuilder struct {
+	clauses []string
+}
+
+// NewWhere creates an empty where expression, clauses are joined with "and"
+func NewWhere() *WhereBuilder {
+	return &WhereBuilder{}
+}
+
+// Equals adds properties["property"] == value
+func (w *WhereBuilder) Equals(property string, value any) *WhereBuilder {
+	return w.compare(property, "==", value)
+}
+
+// NotEquals adds properties["property"] != value
+func (w *WhereBuilder) NotEquals(property string, value any) *WhereBuilder {
+	return w.compare(property, "!=", value)
+}
+
+// GreaterThan adds properties["property"] > value
+func (w *WhereBuilder) GreaterThan(property string, value any) *WhereBuilder {
+	return w.compare(property, ">", value)
+}
+
+// LessThan adds properties["property"] < value
+func (w *WhereBuilder) LessThan(property string, value any) *WhereBuilder {
+	return w.compare(property, "<", value)
+}
+
+// Defined adds defined(properties["property"])
+func (w *WhereBuilder) Defined(property string) *WhereBuilder {
+	w.clauses = append(w.clauses, fmt.Sprintf("defined(%s)", whereProperty(property)))
+	return w
+}
+
+// String is the where expression, empty if no clauses were added
+func (w *WhereBuilder) String() string {
+	if w == nil {
+		return ""
+	}
+	return strings.Join(w.clauses, " and ")
+}
+
+func (w *WhereBuilder) compare(property, operator string, value any) *WhereBuilder {
+	w.clauses = append(w.clauses, fmt.Sprintf("%s %s %s", whereProperty(property), operator, whereValue(value)))
+	return w
+}
+
+var whereEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// whereString quotes s as a string literal, only backslashes and double quotes are escaped
+func whereString(s string) string {
+	return `"` + whereEscaper.Replace(s) + `"`
+}
+
+func whereProperty(property string) string {
+	return fmt.Sprintf("properties[%s]", whereString(property))
+}
+
+// whereValue formats a literal, anything that isn't a number or bool is quoted as a string
+func whereValue(value any) string {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.String:
+		return whereString(v.String())
+	default:
+		return whereString(fmt.Sprint(value))
+	}
+}
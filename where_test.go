@@ -0,0 +1,47 @@
+package mixpanel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereBuilder(t *testing.T) {
+	t.Run("joins clauses with and", func(t *testing.T) {
+		where := NewWhere().
+			Equals("$email", "some-email").
+			NotEquals("plan", "free").
+			GreaterThan("seats", 5).
+			LessThan("score", 0.5).
+			Equals("active", true).
+			Defined("company")
+
+		require.Equal(t, `properties["$email"] == "some-email" and properties["plan"] != "free" and properties["seats"] > 5 and properties["score"] < 0.5 and properties["active"] == true and defined(properties["company"])`, where.String())
+	})
+
+	t.Run("escapes names and values", func(t *testing.T) {
+		where := NewWhere().Equals(`na"me`, `" or true or "`)
+		require.Equal(t, `properties["na\"me"] == "\" or true or \""`, where.String())
+
+		where = NewWhere().Equals(`back\slash`, `日本\n`)
+		require.Equal(t, `properties["back\\slash"] == "日本\\n"`, where.String())
+	})
+
+	t.Run("formats all numeric kinds", func(t *testing.T) {
+		type seats int32
+		where := NewWhere().
+			Equals("a", int8(-1)).
+			Equals("b", uint64(18446744073709551615)).
+			Equals("c", float32(0.1)).
+			Equals("d", seats(4))
+
+		require.Equal(t, `properties["a"] == -1 and properties["b"] == 18446744073709551615 and properties["c"] == 0.1 and properties["d"] == 4`, where.String())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		require.Equal(t, "", NewWhere().String())
+
+		var where *WhereBuilder
+		require.Equal(t, "", where.String())
+	})
+}